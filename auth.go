@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	authSchemeAPIKey = "api-key"
+	authSchemeBearer = "bearer"
+
+	// tokenExpiryLeeway renews expiring tokens slightly early so a request
+	// doesn't race the expiry.
+	tokenExpiryLeeway = 30 * time.Second
+)
+
+// credential is the header attached to every request to Dependency-Track.
+// A zero expiry means the credential doesn't expire.
+type credential struct {
+	header string
+	value  string
+	expiry time.Time
+}
+
+func newCredential(scheme string, secret string) credential {
+	if scheme == authSchemeBearer {
+		return credential{header: "Authorization", value: "Bearer " + secret}
+	}
+	return credential{header: "X-Api-Key", value: secret}
+}
+
+// credentialSource obtains a credential, e.g. by reading a file or exchanging
+// a token. It is called again whenever the cached credential expires or is
+// rejected with a 401.
+type credentialSource interface {
+	credential(ctx context.Context) (credential, error)
+}
+
+type credentialSourceFunc func(ctx context.Context) (credential, error)
+
+func (f credentialSourceFunc) credential(ctx context.Context) (credential, error) {
+	return f(ctx)
+}
+
+// authError marks failures to obtain a credential, which retrying the request
+// won't fix.
+type authError struct{ err error }
+
+func (e *authError) Error() string { return "failed to obtain credentials: " + e.err.Error() }
+func (e *authError) Unwrap() error { return e.err }
+
+// authenticator caches the credential from its source and applies it to
+// requests. This is the only place requests are authenticated.
+type authenticator struct {
+	source      credentialSource
+	refreshable bool
+
+	mu        sync.Mutex
+	cached    *credential
+	refreshed bool
+}
+
+func newStaticAuthenticator(cred credential) *authenticator {
+	return &authenticator{
+		source: credentialSourceFunc(func(context.Context) (credential, error) { return cred, nil }),
+	}
+}
+
+func newRefreshableAuthenticator(source credentialSource) *authenticator {
+	return &authenticator{source: source, refreshable: true}
+}
+
+// newAuthenticator builds the authenticator for the auth method selected in
+// cfg. client is used for the OIDC token exchange.
+func newAuthenticator(cfg *Config, client *http.Client) (*authenticator, error) {
+	secrets.add(cfg.APIKey)
+	secrets.add(cfg.BearerToken)
+	switch {
+	case cfg.APIKey != "":
+		return newStaticAuthenticator(newCredential(authSchemeAPIKey, cfg.APIKey)), nil
+	case cfg.BearerToken != "":
+		return newStaticAuthenticator(newCredential(authSchemeBearer, cfg.BearerToken)), nil
+	case cfg.APIKeyFile != "":
+		return newRefreshableAuthenticator(fileCredentialSource(cfg.APIKeyFile, cfg.AuthScheme)), nil
+	case cfg.CredentialHelper != "":
+		return newRefreshableAuthenticator(execCredentialSource(cfg.CredentialHelper, cfg.AuthScheme)), nil
+	case cfg.OIDCTokenURL != "":
+		ciToken, err := ciTokenSource(cfg, client)
+		if err != nil {
+			return nil, err
+		}
+		return newRefreshableAuthenticator(oidcCredentialSource(cfg.OIDCTokenURL, cfg.OIDCAudience, ciToken, client)), nil
+	}
+	return nil, errors.New("no authentication method configured")
+}
+
+func (a *authenticator) current(ctx context.Context) (credential, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cached != nil && (a.cached.expiry.IsZero() || time.Now().Before(a.cached.expiry)) {
+		return *a.cached, nil
+	}
+	cred, err := a.source.credential(ctx)
+	if err != nil {
+		return credential{}, &authError{err}
+	}
+	secrets.add(strings.TrimPrefix(cred.value, "Bearer "))
+	a.cached = &cred
+	return cred, nil
+}
+
+// invalidate drops the cached credential after a 401 and reports whether the
+// request is worth retrying with a fresh one. Only one refresh is attempted
+// until a request succeeds again, so a revoked credential fails fast.
+func (a *authenticator) invalidate() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.refreshable || a.refreshed {
+		return false
+	}
+	a.cached = nil
+	a.refreshed = true
+	return true
+}
+
+func (a *authenticator) accepted() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.refreshed = false
+}
+
+// authTransport authenticates every request passing through it.
+type authTransport struct {
+	base http.RoundTripper
+	auth *authenticator
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cred, err := t.auth.current(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(cred.header, cred.value)
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode != http.StatusUnauthorized {
+		t.auth.accepted()
+	}
+	return resp, err
+}
+
+// fileCredentialSource re-reads the secret from path on every refresh, so a
+// rotated key is picked up without restarting.
+func fileCredentialSource(path string, scheme string) credentialSource {
+	return credentialSourceFunc(func(context.Context) (credential, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return credential{}, err
+		}
+		secret := strings.TrimSpace(string(b))
+		if secret == "" {
+			return credential{}, fmt.Errorf("credential file %s is empty", path)
+		}
+		return newCredential(scheme, secret), nil
+	})
+}
+
+// execCredentialSource runs command and uses its trimmed stdout as the
+// secret. The command line is split on whitespace and not passed to a shell.
+func execCredentialSource(command string, scheme string) credentialSource {
+	return credentialSourceFunc(func(ctx context.Context) (credential, error) {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return credential{}, errors.New("credential helper command is empty")
+		}
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return credential{}, fmt.Errorf("credential helper %q failed: %w", args[0], err)
+		}
+		secret := strings.TrimSpace(string(out))
+		if secret == "" {
+			return credential{}, fmt.Errorf("credential helper %q printed no credential", args[0])
+		}
+		return newCredential(scheme, secret), nil
+	})
+}
+
+// oidcCredentialSource exchanges the CI job's OIDC token for an access token
+// at tokenURL using OAuth 2.0 Token Exchange (RFC 8693).
+func oidcCredentialSource(tokenURL string, audience string, ciToken func(ctx context.Context) (string, error), client *http.Client) credentialSource {
+	return credentialSourceFunc(func(ctx context.Context) (credential, error) {
+		subject, err := ciToken(ctx)
+		if err != nil {
+			return credential{}, err
+		}
+		secrets.add(subject)
+		form := url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {subject},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:jwt"},
+		}
+		if audience != "" {
+			form.Set("audience", audience)
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return credential{}, fmt.Errorf("failed to create token exchange request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return credential{}, fmt.Errorf("token exchange failed: %w", err)
+		}
+		defer func(Body io.ReadCloser) {
+			_ = Body.Close()
+		}(resp.Body)
+
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			return credential{}, fmt.Errorf("token exchange failed with status %d: %s", resp.StatusCode, respBody)
+		}
+
+		var tokenResp struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+			return credential{}, fmt.Errorf("failed to parse token exchange response: %w", err)
+		}
+		if tokenResp.AccessToken == "" {
+			return credential{}, errors.New("token exchange response contained no access_token")
+		}
+		cred := newCredential(authSchemeBearer, tokenResp.AccessToken)
+		if tokenResp.ExpiresIn > 0 {
+			// Short-lived tokens are renewed halfway through instead, so the
+			// leeway never makes a token expire on arrival.
+			lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
+			cred.expiry = time.Now().Add(max(lifetime/2, lifetime-tokenExpiryLeeway))
+		}
+		return cred, nil
+	})
+}
+
+// ciTokenSource returns a function fetching the CI job's OIDC token: from the
+// environment variable named by --oidc-token-env (e.g. a GitLab id_token), or
+// from GitHub Actions' token endpoint when running there with id-token: write.
+func ciTokenSource(cfg *Config, client *http.Client) (func(ctx context.Context) (string, error), error) {
+	if cfg.OIDCTokenEnv != "" {
+		return func(context.Context) (string, error) {
+			token := os.Getenv(cfg.OIDCTokenEnv)
+			if token == "" {
+				return "", fmt.Errorf("environment variable %s is empty", cfg.OIDCTokenEnv)
+			}
+			return token, nil
+		}, nil
+	}
+	requestURL, requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return nil, errors.New("no CI OIDC token available: set --oidc-token-env, or run in GitHub Actions with id-token: write permission")
+	}
+	secrets.add(requestToken)
+	return func(ctx context.Context) (string, error) {
+		return fetchGitHubActionsToken(ctx, requestURL, requestToken, cfg.OIDCAudience, client)
+	}, nil
+}
+
+func fetchGitHubActionsToken(ctx context.Context, requestURL string, requestToken string, audience string, client *http.Client) (string, error) {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	if audience != "" {
+		q := u.Query()
+		q.Set("audience", audience)
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub OIDC token request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("GitHub OIDC token request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("GitHub OIDC token request failed with status %d: %s", resp.StatusCode, respBody)
+	}
+	var tokenResp struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("failed to parse GitHub OIDC token response: %w", err)
+	}
+	return tokenResp.Value, nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func authConfig() *Config {
+	return &Config{RetryMax: 3, AuthScheme: authSchemeAPIKey}
+}
+
+func TestAuth_StaticAPIKeyAndBearerToken(t *testing.T) {
+	var gotKey, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey, gotAuth = r.Header.Get("X-Api-Key"), r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	cfg := authConfig()
+	cfg.APIKey = "static-key"
+	resp, err := mustRetryClient(t, cfg).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if gotKey != "static-key" || gotAuth != "" {
+		t.Errorf("api key: got X-Api-Key=%q Authorization=%q", gotKey, gotAuth)
+	}
+
+	cfg = authConfig()
+	cfg.BearerToken = "static-token"
+	resp, err = mustRetryClient(t, cfg).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if gotKey != "" || gotAuth != "Bearer static-token" {
+		t.Errorf("bearer: got X-Api-Key=%q Authorization=%q", gotKey, gotAuth)
+	}
+}
+
+func TestAuth_StaticKeyIsNotRetriedOn401(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cfg := authConfig()
+	cfg.APIKey = "revoked"
+	resp, err := mustRetryClient(t, cfg).Get(server.URL)
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", calls.Load())
+	}
+}
+
+func TestAuth_KeyFileIsReReadOn401(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	_ = os.WriteFile(keyFile, []byte("old-key\n"), 0o600)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("X-Api-Key") != "new-key" {
+			// Simulate the key being rotated while the old one is rejected.
+			_ = os.WriteFile(keyFile, []byte("new-key\n"), 0o600)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	cfg := authConfig()
+	cfg.APIKeyFile = keyFile
+	resp, err := mustRetryClient(t, cfg).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status: got %d, want 200", resp.StatusCode)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", calls.Load())
+	}
+}
+
+func TestAuth_RefreshIsAttemptedOnlyOnce(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	_ = os.WriteFile(keyFile, []byte("revoked"), 0o600)
+	cfg := authConfig()
+	cfg.APIKeyFile = keyFile
+	resp, err := mustRetryClient(t, cfg).Get(server.URL)
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 requests (original and one refresh), got %d", calls.Load())
+	}
+}
+
+func TestAuth_CredentialHelperAsBearer(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	cfg := authConfig()
+	cfg.CredentialHelper = "echo helper-token"
+	cfg.AuthScheme = authSchemeBearer
+	resp, err := mustRetryClient(t, cfg).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if gotAuth != "Bearer helper-token" {
+		t.Errorf("Authorization: got %q, want %q", gotAuth, "Bearer helper-token")
+	}
+}
+
+func TestAuth_FailingCredentialHelperIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	cfg := authConfig()
+	cfg.CredentialHelper = "false"
+	if _, err := mustRetryClient(t, cfg).Get(server.URL); err == nil {
+		t.Error("expected error from failing credential helper, got nil")
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no requests to reach the server, got %d", calls.Load())
+	}
+}
+
+func TestAuth_OIDCTokenExchange(t *testing.T) {
+	t.Setenv("CI_ID_TOKEN", "ci-jwt")
+	var exchanges atomic.Int32
+	var gotSubject, gotAudience, gotGrant string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges.Add(1)
+		_ = r.ParseForm()
+		gotSubject, gotAudience, gotGrant = r.PostForm.Get("subject_token"), r.PostForm.Get("audience"), r.PostForm.Get("grant_type")
+		// A lifetime shorter than the leeway is still used for half of it.
+		_, _ = w.Write([]byte(`{"access_token":"exchanged-token","expires_in":10}`))
+	}))
+	defer tokenServer.Close()
+
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	cfg := authConfig()
+	cfg.OIDCTokenURL = tokenServer.URL
+	cfg.OIDCAudience = "dependency-track"
+	cfg.OIDCTokenEnv = "CI_ID_TOKEN"
+	client := mustRetryClient(t, cfg)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	if gotAuth != "Bearer exchanged-token" {
+		t.Errorf("Authorization: got %q, want %q", gotAuth, "Bearer exchanged-token")
+	}
+	if gotSubject != "ci-jwt" || gotAudience != "dependency-track" || gotGrant != "urn:ietf:params:oauth:grant-type:token-exchange" {
+		t.Errorf("exchange form: subject=%q audience=%q grant=%q", gotSubject, gotAudience, gotGrant)
+	}
+	if exchanges.Load() != 1 {
+		t.Errorf("expected the short-lived token to be reused, got %d exchanges", exchanges.Load())
+	}
+}
+
+func TestAuth_GitHubActionsOIDCToken(t *testing.T) {
+	restoreSecrets(t)
+	var gotRequestAuth, gotAudience string
+	actions := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRequestAuth, gotAudience = r.Header.Get("Authorization"), r.URL.Query().Get("audience")
+		_, _ = w.Write([]byte(`{"value":"github-jwt"}`))
+	}))
+	defer actions.Close()
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", actions.URL+"/token?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+
+	ciToken, err := ciTokenSource(&Config{OIDCAudience: "dtrack"}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	token, err := ciToken(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "github-jwt" {
+		t.Errorf("token: got %q, want %q", token, "github-jwt")
+	}
+	if gotRequestAuth != "Bearer request-token" || gotAudience != "dtrack" {
+		t.Errorf("request: Authorization=%q audience=%q", gotRequestAuth, gotAudience)
+	}
+	if got := secrets.redact("token request-token"); got != "token "+redacted {
+		t.Errorf("expected the request token to be redacted, got %q", got)
+	}
+}
+
+func TestAuth_NoCIOIDCTokenReturnsError(t *testing.T) {
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+	if _, err := ciTokenSource(&Config{}, http.DefaultClient); err == nil {
+		t.Error("expected error without a CI OIDC token, got nil")
+	}
+}
+
+func TestAuthenticator_ExpiredCredentialIsRenewed(t *testing.T) {
+	var calls int
+	a := newRefreshableAuthenticator(credentialSourceFunc(func(context.Context) (credential, error) {
+		calls++
+		return credential{header: "X-Api-Key", value: "k", expiry: time.Now().Add(-time.Second)}, nil
+	}))
+	_, _ = a.current(context.Background())
+	_, _ = a.current(context.Background())
+	if calls != 2 {
+		t.Errorf("expected expired credential to be fetched again, got %d fetches", calls)
+	}
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -16,17 +19,110 @@ type Config struct {
 	Version string
 	Parent  string
 	Tags    string
+	Teams   []string
 	SBOM    string
+	VEX     string
 	Poll    bool
 	Latest  bool
+	DryRun  bool
+
+	NotificationRules []string
+
+	PollTimeout    time.Duration
+	PollInterval   time.Duration
+	WaitForMetrics bool
+	MetricsFile    string
+	TopComponents  int
+
+	FailOnEPSS         float64
+	FailOnEPSSSeverity string
+	KEVCatalog         string
+
+	LicenseDeny    string
+	LicenseAllow   string
+	LicenseUnknown string
+
+	OSVDB       string
+	OSVFallback string
+
+	RetryMax     int
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	RetryBudget  time.Duration
+
+	CACert             string
+	ClientCert         string
+	ClientKey          string
+	InsecureSkipVerify bool
+	Proxy              string
+	NoProxy            string
+
+	BearerToken      string
+	APIKeyFile       string
+	CredentialHelper string
+	AuthScheme       string
+	OIDCTokenURL     string
+	OIDCAudience     string
+	OIDCTokenEnv     string
+
+	LogLevel  string
+	LogFormat string
+
+	TraceFile         string
+	TraceOTLPEndpoint string
+
+	Record string
+	Replay string
 }
 
-func (c *Config) validate() error {
+// validateConnection checks only the inputs needed to talk to Dependency-Track,
+// for subcommands that don't upload an SBOM.
+func (c *Config) validateConnection() error {
 	if c.URL == "" {
 		return fmt.Errorf("missing required input: url (via --url or SBOM_UPLOADER_URL)")
 	}
-	if c.APIKey == "" {
-		return fmt.Errorf("missing required input: api-key (via --api-key or SBOM_UPLOADER_API_KEY)")
+	methods := 0
+	for _, set := range []string{c.APIKey, c.BearerToken, c.APIKeyFile, c.CredentialHelper, c.OIDCTokenURL} {
+		if set != "" {
+			methods++
+		}
+	}
+	if methods == 0 && c.Replay == "" {
+		return fmt.Errorf("missing required input: api-key (via --api-key or SBOM_UPLOADER_API_KEY), or one of --bearer-token, --api-key-file, --credential-helper, --oidc-token-url")
+	}
+	if methods > 1 {
+		return fmt.Errorf("only one of --api-key, --bearer-token, --api-key-file, --credential-helper and --oidc-token-url may be set")
+	}
+	if c.Record != "" && c.Replay != "" {
+		return fmt.Errorf("only one of --record and --replay may be set")
+	}
+	if c.AuthScheme != authSchemeAPIKey && c.AuthScheme != authSchemeBearer {
+		return fmt.Errorf("invalid auth-scheme %q: must be %s or %s", c.AuthScheme, authSchemeAPIKey, authSchemeBearer)
+	}
+	if c.PollTimeout < 0 {
+		return fmt.Errorf("invalid poll-timeout %s: must not be negative", c.PollTimeout)
+	}
+	if c.PollInterval < 0 {
+		return fmt.Errorf("invalid poll-interval %s: must not be negative", c.PollInterval)
+	}
+	if c.RetryMax < 0 {
+		return fmt.Errorf("invalid retry-max %d: must not be negative", c.RetryMax)
+	}
+	if c.RetryWaitMin < 0 || c.RetryWaitMax < c.RetryWaitMin {
+		return fmt.Errorf("invalid retry-wait-min %s / retry-wait-max %s: must satisfy 0 <= min <= max", c.RetryWaitMin, c.RetryWaitMax)
+	}
+	if c.RetryBudget < 0 {
+		return fmt.Errorf("invalid retry-budget %s: must not be negative", c.RetryBudget)
+	}
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		return fmt.Errorf("client-cert and client-key must be set together")
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if err := c.validateConnection(); err != nil {
+		return err
 	}
 	if c.Name == "" {
 		return fmt.Errorf("missing required input: name (via --name or SBOM_UPLOADER_NAME)")
@@ -37,11 +133,59 @@ func (c *Config) validate() error {
 	if c.Version == "" {
 		return fmt.Errorf("missing required input: version (via --version or SBOM_UPLOADER_VERSION)")
 	}
+	if c.TopComponents < 0 {
+		return fmt.Errorf("invalid top-components %d: must not be negative", c.TopComponents)
+	}
+	if c.FailOnEPSS < 0 || c.FailOnEPSS > 1 {
+		return fmt.Errorf("invalid fail-on-epss %g: must be a probability between 0 and 1", c.FailOnEPSS)
+	}
+	if c.FailOnEPSSSeverity != "" {
+		if c.FailOnEPSS == 0 {
+			return fmt.Errorf("fail-on-epss-severity requires --fail-on-epss")
+		}
+		if _, err := parseSeverities(c.FailOnEPSSSeverity); err != nil || strings.Contains(c.FailOnEPSSSeverity, ",") {
+			return fmt.Errorf("invalid fail-on-epss-severity %q: must be one of %s", c.FailOnEPSSSeverity, strings.Join(severities, ", "))
+		}
+	}
+	if _, err := c.licensePolicy(); err != nil {
+		return err
+	}
 	return nil
 }
 
-// loadConfig resolves configuration from flags and environment variables.
-// Flags take precedence over env vars; env vars take precedence over defaults.
+// pollWait is the initial wait between polls. A replay answers from the
+// recording at once, so it doesn't wait at all.
+func (c *Config) pollWait() time.Duration {
+	if c.Replay != "" {
+		return 0
+	}
+	return c.PollInterval
+}
+
+// licensePolicy returns the policy given by --license-deny, --license-allow
+// and --license-unknown.
+func (c *Config) licensePolicy() (licensePolicy, error) {
+	policy := licensePolicy{Unknown: c.LicenseUnknown}
+	if policy.Unknown != licenseUnknownWarn && policy.Unknown != licenseUnknownFail {
+		return policy, fmt.Errorf("invalid license-unknown %q: must be %s or %s", c.LicenseUnknown, licenseUnknownWarn, licenseUnknownFail)
+	}
+	var err error
+	if policy.Deny, err = parseLicensePatterns(c.LicenseDeny); err != nil {
+		return policy, fmt.Errorf("invalid license-deny: %w", err)
+	}
+	if policy.Allow, err = parseLicensePatterns(c.LicenseAllow); err != nil {
+		return policy, fmt.Errorf("invalid license-allow: %w", err)
+	}
+	return policy, nil
+}
+
+// configFileName is looked up in the working directory, then the home
+// directory, when --config isn't given.
+const configFileName = ".sbom-uploader.yaml"
+
+// loadConfig resolves configuration from flags, environment variables and the
+// config file. Precedence is flag > env var > selected profile > top-level
+// config file settings > flag defaults.
 //
 // AutomaticEnv + BindPFlags has a known issue where bool flags with a false
 // default have their pflag default shadow the env var. We work around it with
@@ -56,9 +200,15 @@ func loadConfig(flags *pflag.FlagSet) (*Config, error) {
 		v.BindPFlags(flags),
 		v.BindEnv("poll", "SBOM_UPLOADER_POLL"),
 		v.BindEnv("latest", "SBOM_UPLOADER_LATEST"),
+		v.BindEnv("wait-for-metrics", "SBOM_UPLOADER_WAIT_FOR_METRICS"),
+		v.BindEnv("dry-run", "SBOM_UPLOADER_DRY_RUN"),
+		v.BindEnv("insecure-skip-verify", "SBOM_UPLOADER_INSECURE_SKIP_VERIFY"),
 	); err != nil {
 		return nil, err
 	}
+	if err := readConfigFile(v); err != nil {
+		return nil, err
+	}
 	return &Config{
 		URL:     v.GetString("url"),
 		APIKey:  v.GetString("api-key"),
@@ -66,20 +216,210 @@ func loadConfig(flags *pflag.FlagSet) (*Config, error) {
 		Version: v.GetString("version"),
 		Parent:  v.GetString("parent"),
 		Tags:    v.GetString("tags"),
+		Teams:   getStringList(v, "team"),
 		SBOM:    v.GetString("sbom"),
+		VEX:     v.GetString("vex"),
 		Poll:    v.GetBool("poll"),
 		Latest:  v.GetBool("latest"),
+		DryRun:  v.GetBool("dry-run"),
+
+		NotificationRules: getStringList(v, "notification-rule"),
+
+		PollTimeout:    v.GetDuration("poll-timeout"),
+		PollInterval:   v.GetDuration("poll-interval"),
+		WaitForMetrics: v.GetBool("wait-for-metrics"),
+		MetricsFile:    v.GetString("metrics-file"),
+		TopComponents:  v.GetInt("top-components"),
+
+		FailOnEPSS:         v.GetFloat64("fail-on-epss"),
+		FailOnEPSSSeverity: v.GetString("fail-on-epss-severity"),
+		KEVCatalog:         v.GetString("kev-catalog"),
+
+		LicenseDeny:    v.GetString("license-deny"),
+		LicenseAllow:   v.GetString("license-allow"),
+		LicenseUnknown: v.GetString("license-unknown"),
+
+		OSVDB:       v.GetString("osv-db"),
+		OSVFallback: v.GetString("osv-fallback"),
+
+		RetryMax:     v.GetInt("retry-max"),
+		RetryWaitMin: v.GetDuration("retry-wait-min"),
+		RetryWaitMax: v.GetDuration("retry-wait-max"),
+		RetryBudget:  v.GetDuration("retry-budget"),
+
+		CACert:             v.GetString("ca-cert"),
+		ClientCert:         v.GetString("client-cert"),
+		ClientKey:          v.GetString("client-key"),
+		InsecureSkipVerify: v.GetBool("insecure-skip-verify"),
+		Proxy:              v.GetString("proxy"),
+		NoProxy:            v.GetString("no-proxy"),
+
+		BearerToken:      v.GetString("bearer-token"),
+		APIKeyFile:       v.GetString("api-key-file"),
+		CredentialHelper: v.GetString("credential-helper"),
+		AuthScheme:       v.GetString("auth-scheme"),
+		OIDCTokenURL:     v.GetString("oidc-token-url"),
+		OIDCAudience:     v.GetString("oidc-audience"),
+		OIDCTokenEnv:     v.GetString("oidc-token-env"),
+
+		LogLevel:  v.GetString("log-level"),
+		LogFormat: v.GetString("log-format"),
+
+		TraceFile:         v.GetString("trace-file"),
+		TraceOTLPEndpoint: v.GetString("trace-otlp-endpoint"),
+
+		Record: v.GetString("record"),
+		Replay: v.GetString("replay"),
 	}, nil
 }
 
-func setFlags(s *pflag.FlagSet) {
+// getStringList reads a repeatable flag. Env vars hold a comma-separated list,
+// config files a YAML list.
+func getStringList(v *viper.Viper, key string) []string {
+	if s, ok := v.Get(key).(string); ok {
+		var out []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+	return v.GetStringSlice(key)
+}
+
+// readConfigFile merges the config file into v's config layer, so flags and
+// env vars still override it. Settings use the flag names as keys; named
+// profiles under "profiles" override the top-level settings when selected
+// with --profile:
+//
+//	parent: platform
+//	profiles:
+//	  prod:
+//	    url: https://dtrack.example.com
+//	    api-key-file: /run/secrets/dtrack-prod
+func readConfigFile(v *viper.Viper) error {
+	path, profile := v.GetString("config"), v.GetString("profile")
+	if path == "" {
+		path = discoverConfigFile()
+	}
+	if path == "" {
+		if profile != "" {
+			return fmt.Errorf("profile %q selected but no config file found: pass --config or create %s", profile, configFileName)
+		}
+		return nil
+	}
+
+	file := viper.New()
+	file.SetConfigFile(path)
+	file.SetConfigType("yaml")
+	if err := file.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	settings := file.AllSettings()
+	profiles, _ := settings["profiles"].(map[string]any)
+	delete(settings, "profiles")
+	if err := v.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("failed to load config file %s: %w", path, err)
+	}
+	if profile == "" {
+		return nil
+	}
+	selected, ok := profiles[strings.ToLower(profile)].(map[string]any)
+	if !ok {
+		return fmt.Errorf("profile %q not found in config file %s", profile, path)
+	}
+	if err := v.MergeConfigMap(selected); err != nil {
+		return fmt.Errorf("failed to load profile %q from %s: %w", profile, path, err)
+	}
+	return nil
+}
+
+// discoverConfigFile returns the path of the first configFileName found in
+// the working directory or the home directory, or "" if there is none.
+func discoverConfigFile() string {
+	var dirs []string
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// setConnectionFlags registers the flags shared by every command that talks to
+// Dependency-Track.
+func setConnectionFlags(s *pflag.FlagSet) {
 	s.String("url", "", "Dependency-Track API base URL or env SBOM_UPLOADER_URL")
 	s.String("api-key", "", "Dependency-Track API key or env SBOM_UPLOADER_API_KEY")
+	s.String("bearer-token", "", "Static bearer token instead of an API key or env SBOM_UPLOADER_BEARER_TOKEN")
+	s.String("api-key-file", "", "File to read the credential from, re-read on 401, or env SBOM_UPLOADER_API_KEY_FILE")
+	s.String("credential-helper", "", "Command printing the credential on stdout, re-run on 401, or env SBOM_UPLOADER_CREDENTIAL_HELPER")
+	s.String("auth-scheme", authSchemeAPIKey, "How --api-key-file and --credential-helper credentials are sent: api-key or bearer, or env SBOM_UPLOADER_AUTH_SCHEME")
+	s.String("oidc-token-url", "", "Token endpoint to exchange the CI OIDC token for a bearer token or env SBOM_UPLOADER_OIDC_TOKEN_URL")
+	s.String("oidc-audience", "", "Audience to request for the CI OIDC token or env SBOM_UPLOADER_OIDC_AUDIENCE")
+	s.String("oidc-token-env", "", "Environment variable holding the CI OIDC token (default GitHub Actions' token endpoint) or env SBOM_UPLOADER_OIDC_TOKEN_ENV")
+	s.Int("retry-max", 20, "Maximum retries per request or env SBOM_UPLOADER_RETRY_MAX")
+	s.Duration("retry-wait-min", time.Second, "Minimum wait between retries or env SBOM_UPLOADER_RETRY_WAIT_MIN")
+	s.Duration("retry-wait-max", 30*time.Second, "Maximum wait between retries, unless the server sends Retry-After, or env SBOM_UPLOADER_RETRY_WAIT_MAX")
+	s.Duration("retry-budget", 5*time.Minute, "Total time to spend waiting on retries across the run, 0 for unlimited, or env SBOM_UPLOADER_RETRY_BUDGET")
+	s.String("ca-cert", "", "PEM file of additional CA certificates to trust or env SBOM_UPLOADER_CA_CERT")
+	s.String("client-cert", "", "PEM client certificate for mutual TLS or env SBOM_UPLOADER_CLIENT_CERT")
+	s.String("client-key", "", "PEM private key for --client-cert or env SBOM_UPLOADER_CLIENT_KEY")
+	s.Bool("insecure-skip-verify", false, "Disable TLS certificate verification (INSECURE) or env SBOM_UPLOADER_INSECURE_SKIP_VERIFY")
+	s.String("proxy", "", "Proxy URL for all requests, overriding HTTP(S)_PROXY, or env SBOM_UPLOADER_PROXY")
+	s.String("no-proxy", "", "Comma-separated hosts to bypass --proxy for or env SBOM_UPLOADER_NO_PROXY")
+	s.String("record", "", "Directory to record every request and response to, with secrets redacted, or env SBOM_UPLOADER_RECORD")
+	s.String("replay", "", "Directory of a recording to replay instead of contacting the server or env SBOM_UPLOADER_REPLAY")
+}
+
+// setGlobalFlags registers the config file and logging flags, shared by all
+// commands as persistent flags of the root command.
+func setGlobalFlags(s *pflag.FlagSet) {
+	s.String("config", "", "Config file (default "+configFileName+" in the working or home directory) or env SBOM_UPLOADER_CONFIG")
+	s.String("profile", "", "Named profile from the config file or env SBOM_UPLOADER_PROFILE")
+	s.String("log-level", "info", "Log level: debug, info, warn or error, or env SBOM_UPLOADER_LOG_LEVEL")
+	s.String("log-format", "text", "Log format: text or json, or env SBOM_UPLOADER_LOG_FORMAT")
+	s.String("trace-file", "", "Write OpenTelemetry spans as JSON to this file, or env SBOM_UPLOADER_TRACE_FILE")
+	s.String("trace-otlp-endpoint", "", "Export OpenTelemetry spans over OTLP/HTTP to this URL, or env SBOM_UPLOADER_TRACE_OTLP_ENDPOINT")
+}
+
+// setPollFlags registers the flags controlling how long and how often import
+// status is polled.
+func setPollFlags(s *pflag.FlagSet) {
+	s.Duration("poll-timeout", 5*time.Minute, "Maximum time to wait for processing, 0 to wait indefinitely, or env SBOM_UPLOADER_POLL_TIMEOUT")
+	s.Duration("poll-interval", 2*time.Second, "Initial wait between polls, doubled up to 30s, or env SBOM_UPLOADER_POLL_INTERVAL")
+}
+
+func setFlags(s *pflag.FlagSet) {
+	setConnectionFlags(s)
 	s.String("name", "", "Project name or env SBOM_UPLOADER_NAME")
 	s.String("version", "", "Project version or env SBOM_UPLOADER_VERSION")
 	s.String("parent", "", "Parent project name or env SBOM_UPLOADER_PARENT")
 	s.Bool("latest", true, "Mark as latest version (default true)")
 	s.Bool("poll", false, "Poll until import completes or env SBOM_UPLOADER_POLL")
+	setPollFlags(s)
+	s.Bool("wait-for-metrics", false, "After import, refresh project metrics and wait for them before the summary (implies --poll) or env SBOM_UPLOADER_WAIT_FOR_METRICS")
+	s.Int("top-components", defaultTopComponents, "With --poll, list this many of the most vulnerable components, 0 to disable, or env SBOM_UPLOADER_TOP_COMPONENTS")
+	s.Float64("fail-on-epss", 0, "Fail if an unsuppressed finding's EPSS score is above this probability (implies --poll), 0 to disable, or env SBOM_UPLOADER_FAIL_ON_EPSS")
+	s.String("fail-on-epss-severity", "", "Only let --fail-on-epss fail on findings of at least this severity, e.g. HIGH, or env SBOM_UPLOADER_FAIL_ON_EPSS_SEVERITY")
+	s.String("kev-catalog", "", "CISA Known Exploited Vulnerabilities JSON file; fail if an unsuppressed finding is listed (implies --poll) or env SBOM_UPLOADER_KEV_CATALOG")
+	s.String("license-deny", "", "Comma-separated SPDX license patterns, e.g. GPL-3.0-only,AGPL-*, that fail the run before upload, or env SBOM_UPLOADER_LICENSE_DENY")
+	s.String("license-allow", "", "Comma-separated SPDX license patterns; any other license fails the run before upload, or env SBOM_UPLOADER_LICENSE_ALLOW")
+	s.String("license-unknown", licenseUnknownWarn, "With a license policy, warn or fail on components without an SPDX license, or env SBOM_UPLOADER_LICENSE_UNKNOWN")
+	s.String("osv-fallback", "", "OSV database to scan the SBOM against if Dependency-Track is unavailable, see the scan command, or env SBOM_UPLOADER_OSV_FALLBACK")
+	s.String("metrics-file", "", "Write Prometheus/OpenMetrics gauges about the upload to this file or env SBOM_UPLOADER_METRICS_FILE")
 	s.String("tags", "", "Comma-separated project tags or env SBOM_UPLOADER_TAGS")
+	s.StringArray("team", nil, "Team name or UUID to grant access to newly created projects (repeatable) or env SBOM_UPLOADER_TEAM (comma-separated)")
+	s.StringArray("notification-rule", nil, "Notification rule name to subscribe the parent and project to (repeatable) or env SBOM_UPLOADER_NOTIFICATION_RULE (comma-separated)")
 	s.String("sbom", "", "Path to SBOM file (optional; otherwise read from stdin)")
+	s.String("vex", "", "Path to CycloneDX VEX file to upload after the SBOM is imported or env SBOM_UPLOADER_VEX")
+	s.Bool("dry-run", false, "Validate the SBOM and print the requests that would be sent, without sending them, or env SBOM_UPLOADER_DRY_RUN")
 }
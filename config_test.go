@@ -1,8 +1,11 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -11,10 +14,12 @@ import (
 func newFlagSet() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
 	setFlags(flags)
+	setGlobalFlags(flags)
 	return flags
 }
 
 func TestLoadConfig_StringsFromEnvVars(t *testing.T) {
+	isolateConfigDiscovery(t)
 	t.Setenv("SBOM_UPLOADER_URL", "https://example.com")
 	t.Setenv("SBOM_UPLOADER_API_KEY", "my-api-key")
 	t.Setenv("SBOM_UPLOADER_NAME", "my-project")
@@ -52,6 +57,7 @@ func TestLoadConfig_StringsFromEnvVars(t *testing.T) {
 }
 
 func TestLoadConfig_PollFromEnvVar(t *testing.T) {
+	isolateConfigDiscovery(t)
 	t.Setenv("SBOM_UPLOADER_POLL", "true")
 
 	cfg, err := loadConfig(newFlagSet())
@@ -63,7 +69,54 @@ func TestLoadConfig_PollFromEnvVar(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_TransportFromEnvVars(t *testing.T) {
+	isolateConfigDiscovery(t)
+	t.Setenv("SBOM_UPLOADER_CA_CERT", "/ca.pem")
+	t.Setenv("SBOM_UPLOADER_CLIENT_CERT", "/client.pem")
+	t.Setenv("SBOM_UPLOADER_CLIENT_KEY", "/client-key.pem")
+	t.Setenv("SBOM_UPLOADER_INSECURE_SKIP_VERIFY", "true")
+	t.Setenv("SBOM_UPLOADER_PROXY", "http://proxy:3128")
+	t.Setenv("SBOM_UPLOADER_NO_PROXY", "internal")
+
+	cfg, err := loadConfig(newFlagSet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CACert != "/ca.pem" || cfg.ClientCert != "/client.pem" || cfg.ClientKey != "/client-key.pem" {
+		t.Errorf("certificates: got ca=%q cert=%q key=%q", cfg.CACert, cfg.ClientCert, cfg.ClientKey)
+	}
+	if !cfg.InsecureSkipVerify {
+		t.Error("InsecureSkipVerify: expected true from SBOM_UPLOADER_INSECURE_SKIP_VERIFY env var, got false")
+	}
+	if cfg.Proxy != "http://proxy:3128" || cfg.NoProxy != "internal" {
+		t.Errorf("proxy: got %q / %q", cfg.Proxy, cfg.NoProxy)
+	}
+}
+
+func TestLoadConfig_AuthFromEnvVars(t *testing.T) {
+	isolateConfigDiscovery(t)
+	t.Setenv("SBOM_UPLOADER_API_KEY_FILE", "/run/secrets/dtrack")
+	t.Setenv("SBOM_UPLOADER_AUTH_SCHEME", "bearer")
+	t.Setenv("SBOM_UPLOADER_CREDENTIAL_HELPER", "vault read")
+	t.Setenv("SBOM_UPLOADER_BEARER_TOKEN", "token")
+	t.Setenv("SBOM_UPLOADER_OIDC_TOKEN_URL", "https://sts.example.com/token")
+	t.Setenv("SBOM_UPLOADER_OIDC_AUDIENCE", "dtrack")
+	t.Setenv("SBOM_UPLOADER_OIDC_TOKEN_ENV", "CI_JOB_JWT")
+
+	cfg, err := loadConfig(newFlagSet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.APIKeyFile != "/run/secrets/dtrack" || cfg.AuthScheme != "bearer" || cfg.CredentialHelper != "vault read" || cfg.BearerToken != "token" {
+		t.Errorf("auth: got file=%q scheme=%q helper=%q token=%q", cfg.APIKeyFile, cfg.AuthScheme, cfg.CredentialHelper, cfg.BearerToken)
+	}
+	if cfg.OIDCTokenURL != "https://sts.example.com/token" || cfg.OIDCAudience != "dtrack" || cfg.OIDCTokenEnv != "CI_JOB_JWT" {
+		t.Errorf("oidc: got url=%q audience=%q env=%q", cfg.OIDCTokenURL, cfg.OIDCAudience, cfg.OIDCTokenEnv)
+	}
+}
+
 func TestLoadConfig_LatestFromEnvVar(t *testing.T) {
+	isolateConfigDiscovery(t)
 	t.Setenv("SBOM_UPLOADER_LATEST", "false")
 
 	cfg, err := loadConfig(newFlagSet())
@@ -76,6 +129,7 @@ func TestLoadConfig_LatestFromEnvVar(t *testing.T) {
 }
 
 func TestLoadConfig_FromFlags(t *testing.T) {
+	isolateConfigDiscovery(t)
 	flags := newFlagSet()
 	err := flags.Parse([]string{
 		"--url", "https://from-flag.com",
@@ -126,7 +180,32 @@ func TestLoadConfig_FromFlags(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_TeamsFromFlagsAndEnv(t *testing.T) {
+	isolateConfigDiscovery(t)
+	flags := newFlagSet()
+	if err := flags.Parse([]string{"--team", "Security Team", "--team", "Platform"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(cfg.Teams, "|"); got != "Security Team|Platform" {
+		t.Errorf("teams from flags: got %q", got)
+	}
+
+	t.Setenv("SBOM_UPLOADER_TEAM", "Security Team, Platform")
+	cfg, err = loadConfig(newFlagSet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(cfg.Teams, "|"); got != "Security Team|Platform" {
+		t.Errorf("teams from env: got %q", got)
+	}
+}
+
 func TestLoadConfig_FlagOverridesEnvVar(t *testing.T) {
+	isolateConfigDiscovery(t)
 	t.Setenv("SBOM_UPLOADER_URL", "https://from-env.com")
 
 	flags := newFlagSet()
@@ -143,7 +222,147 @@ func TestLoadConfig_FlagOverridesEnvVar(t *testing.T) {
 	}
 }
 
+const testConfigFile = `url: https://file.example.com
+parent: file-parent
+poll: true
+poll-timeout: 10m
+profiles:
+  prod:
+    url: https://prod.example.com
+    api-key: prod-key
+  staging:
+    url: https://staging.example.com
+`
+
+// isolateConfigDiscovery points the working and home directories at an empty
+// temp dir so a developer's own config file can't leak into the test.
+func isolateConfigDiscovery(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	t.Setenv("HOME", dir)
+	return dir
+}
+
+func TestLoadConfig_ConfigFilePrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		env     string
+		flag    string
+		wantURL string
+	}{
+		{"file only", "", "", "", "https://file.example.com"},
+		{"profile over file", "prod", "", "", "https://prod.example.com"},
+		{"env over profile", "prod", "https://env.example.com", "", "https://env.example.com"},
+		{"flag over env", "prod", "https://env.example.com", "https://flag.example.com", "https://flag.example.com"},
+		{"flag over file", "", "", "https://flag.example.com", "https://flag.example.com"},
+		{"env over file", "", "https://env.example.com", "", "https://env.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := isolateConfigDiscovery(t)
+			path := filepath.Join(dir, "uploader.yaml")
+			_ = os.WriteFile(path, []byte(testConfigFile), 0o600)
+			if tt.env != "" {
+				t.Setenv("SBOM_UPLOADER_URL", tt.env)
+			}
+			args := []string{"--config", path}
+			if tt.profile != "" {
+				args = append(args, "--profile", tt.profile)
+			}
+			if tt.flag != "" {
+				args = append(args, "--url", tt.flag)
+			}
+			flags := newFlagSet()
+			if err := flags.Parse(args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+
+			cfg, err := loadConfig(flags)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.URL != tt.wantURL {
+				t.Errorf("URL: got %q, want %q", cfg.URL, tt.wantURL)
+			}
+			// Settings the profile doesn't override still come from the file.
+			if cfg.Parent != "file-parent" || !cfg.Poll || cfg.PollTimeout != 10*time.Minute {
+				t.Errorf("file settings: got parent=%q poll=%t poll-timeout=%s", cfg.Parent, cfg.Poll, cfg.PollTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ProfileFromEnvVar(t *testing.T) {
+	dir := isolateConfigDiscovery(t)
+	path := filepath.Join(dir, "uploader.yaml")
+	_ = os.WriteFile(path, []byte(testConfigFile), 0o600)
+	t.Setenv("SBOM_UPLOADER_CONFIG", path)
+	t.Setenv("SBOM_UPLOADER_PROFILE", "staging")
+
+	cfg, err := loadConfig(newFlagSet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.URL != "https://staging.example.com" || cfg.APIKey != "" {
+		t.Errorf("got url=%q api-key=%q, want staging profile without a key", cfg.URL, cfg.APIKey)
+	}
+}
+
+func TestLoadConfig_DiscoversConfigFile(t *testing.T) {
+	dir := isolateConfigDiscovery(t)
+	_ = os.WriteFile(filepath.Join(dir, configFileName), []byte(testConfigFile), 0o600)
+
+	cfg, err := loadConfig(newFlagSet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.URL != "https://file.example.com" {
+		t.Errorf("URL: got %q, want value from discovered %s", cfg.URL, configFileName)
+	}
+}
+
+func TestLoadConfig_ConfigFileErrors(t *testing.T) {
+	dir := isolateConfigDiscovery(t)
+	path := filepath.Join(dir, "uploader.yaml")
+	_ = os.WriteFile(path, []byte(testConfigFile), 0o600)
+	invalid := filepath.Join(dir, "invalid.yaml")
+	_ = os.WriteFile(invalid, []byte("url: [unterminated"), 0o600)
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantMsg string
+	}{
+		{"missing file", []string{"--config", filepath.Join(dir, "missing.yaml")}, "failed to read config file"},
+		{"invalid YAML", []string{"--config", invalid}, "failed to read config file"},
+		{"unknown profile", []string{"--config", path, "--profile", "dev"}, `profile "dev" not found`},
+		{"profile without file", []string{"--profile", "prod"}, "no config file found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := newFlagSet()
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+			_, err := loadConfig(flags)
+			if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("got error %v, want one containing %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
 func TestLoadConfig_Defaults(t *testing.T) {
+	isolateConfigDiscovery(t)
 	cfg, err := loadConfig(newFlagSet())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -154,17 +373,80 @@ func TestLoadConfig_Defaults(t *testing.T) {
 	if !cfg.Latest {
 		t.Error("Latest: expected true by default, got false")
 	}
+	if cfg.PollTimeout != 5*time.Minute {
+		t.Errorf("PollTimeout: got %s, want 5m", cfg.PollTimeout)
+	}
+	if cfg.PollInterval != 2*time.Second {
+		t.Errorf("PollInterval: got %s, want 2s", cfg.PollInterval)
+	}
+	if cfg.RetryMax != 20 {
+		t.Errorf("RetryMax: got %d, want 20", cfg.RetryMax)
+	}
+	if cfg.RetryBudget != 5*time.Minute {
+		t.Errorf("RetryBudget: got %s, want 5m", cfg.RetryBudget)
+	}
+}
+
+func TestLoadConfig_RetryFromEnvVars(t *testing.T) {
+	isolateConfigDiscovery(t)
+	t.Setenv("SBOM_UPLOADER_RETRY_MAX", "3")
+	t.Setenv("SBOM_UPLOADER_RETRY_WAIT_MIN", "250ms")
+	t.Setenv("SBOM_UPLOADER_RETRY_WAIT_MAX", "10s")
+	t.Setenv("SBOM_UPLOADER_RETRY_BUDGET", "1m")
+
+	cfg, err := loadConfig(newFlagSet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RetryMax != 3 {
+		t.Errorf("RetryMax: got %d, want 3", cfg.RetryMax)
+	}
+	if cfg.RetryWaitMin != 250*time.Millisecond {
+		t.Errorf("RetryWaitMin: got %s, want 250ms", cfg.RetryWaitMin)
+	}
+	if cfg.RetryWaitMax != 10*time.Second {
+		t.Errorf("RetryWaitMax: got %s, want 10s", cfg.RetryWaitMax)
+	}
+	if cfg.RetryBudget != time.Minute {
+		t.Errorf("RetryBudget: got %s, want 1m", cfg.RetryBudget)
+	}
+}
+
+func TestLoadConfig_PollDurationsFromEnvAndFlags(t *testing.T) {
+	isolateConfigDiscovery(t)
+	t.Setenv("SBOM_UPLOADER_POLL_TIMEOUT", "10m")
+	t.Setenv("SBOM_UPLOADER_POLL_INTERVAL", "1s")
+
+	flags := newFlagSet()
+	if err := flags.Parse([]string{"--poll-interval", "5s"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PollTimeout != 10*time.Minute {
+		t.Errorf("PollTimeout: got %s, want 10m from env", cfg.PollTimeout)
+	}
+	if cfg.PollInterval != 5*time.Second {
+		t.Errorf("PollInterval: got %s, want 5s from flag", cfg.PollInterval)
+	}
 }
 
 // --- Config.validate ---
 
 func validConfig() *Config {
 	return &Config{
-		URL:     "https://example.com",
-		APIKey:  "key",
-		Name:    "project",
-		Parent:  "parent",
-		Version: "1.0.0",
+		URL:            "https://example.com",
+		APIKey:         "key",
+		Name:           "project",
+		Parent:         "parent",
+		Version:        "1.0.0",
+		RetryWaitMin:   time.Second,
+		RetryWaitMax:   30 * time.Second,
+		AuthScheme:     authSchemeAPIKey,
+		LicenseUnknown: licenseUnknownWarn,
 	}
 }
 
@@ -174,6 +456,14 @@ func TestValidate_PassesWithAllRequiredFields(t *testing.T) {
 	}
 }
 
+func TestValidate_ReplayNeedsNoCredential(t *testing.T) {
+	cfg := validConfig()
+	cfg.APIKey, cfg.Replay = "", "recording"
+	if err := cfg.validate(); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
 func TestValidate_RequiredFields(t *testing.T) {
 	tests := []struct {
 		field   string
@@ -185,6 +475,20 @@ func TestValidate_RequiredFields(t *testing.T) {
 		{"Name", func(c *Config) { c.Name = "" }, "name"},
 		{"Parent", func(c *Config) { c.Parent = "" }, "parent"},
 		{"Version", func(c *Config) { c.Version = "" }, "version"},
+		{"PollTimeout", func(c *Config) { c.PollTimeout = -time.Second }, "poll-timeout"},
+		{"PollInterval", func(c *Config) { c.PollInterval = -time.Second }, "poll-interval"},
+		{"RetryMax", func(c *Config) { c.RetryMax = -1 }, "retry-max"},
+		{"TopComponents", func(c *Config) { c.TopComponents = -1 }, "top-components"},
+		{"RetryWait", func(c *Config) { c.RetryWaitMin = time.Minute }, "retry-wait-min"},
+		{"ClientKey", func(c *Config) { c.ClientCert = "cert.pem" }, "client-key"},
+		{"MultipleAuthMethods", func(c *Config) { c.BearerToken = "token" }, "only one of"},
+		{"AuthScheme", func(c *Config) { c.AuthScheme = "basic" }, "auth-scheme"},
+		{"RecordAndReplay", func(c *Config) { c.Record, c.Replay = "rec", "rec" }, "--record and --replay"},
+		{"FailOnEPSS", func(c *Config) { c.FailOnEPSS = 10 }, "fail-on-epss"},
+		{"FailOnEPSSSeverityAlone", func(c *Config) { c.FailOnEPSSSeverity = "HIGH" }, "requires --fail-on-epss"},
+		{"FailOnEPSSSeverity", func(c *Config) { c.FailOnEPSS, c.FailOnEPSSSeverity = 0.1, "HIGH,LOW" }, "fail-on-epss-severity"},
+		{"LicenseDeny", func(c *Config) { c.LicenseDeny = "GPL-[" }, "license-deny"},
+		{"LicenseUnknown", func(c *Config) { c.LicenseUnknown = "ignore" }, "license-unknown"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// cdxBOM is the subset of a CycloneDX JSON document this tool reads locally.
+type cdxBOM struct {
+	BOMFormat       string             `json:"bomFormat"`
+	Components      []cdxComponent     `json:"components"`
+	Vulnerabilities []cdxVulnerability `json:"vulnerabilities"`
+}
+
+type cdxComponent struct {
+	BOMRef     string         `json:"bom-ref"`
+	Name       string         `json:"name"`
+	Group      string         `json:"group"`
+	Version    string         `json:"version"`
+	PURL       string         `json:"purl"`
+	Licenses   []cdxLicense   `json:"licenses"`
+	Components []cdxComponent `json:"components"`
+}
+
+// cdxLicense is one entry of a component's licenses: either a license, given
+// by SPDX id or free-form name, or an SPDX license expression.
+type cdxLicense struct {
+	License *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"license"`
+	Expression string `json:"expression"`
+}
+
+type cdxVulnerability struct {
+	ID      string `json:"id"`
+	Ratings []struct {
+		Severity string `json:"severity"`
+	} `json:"ratings"`
+	Affects []struct {
+		Ref string `json:"ref"`
+	} `json:"affects"`
+}
+
+func parseCycloneDX(content []byte) (*cdxBOM, error) {
+	var bom cdxBOM
+	if err := json.Unmarshal(content, &bom); err != nil {
+		return nil, fmt.Errorf("failed to parse CycloneDX JSON: %w", err)
+	}
+	if bom.BOMFormat != "CycloneDX" {
+		return nil, fmt.Errorf("unsupported SBOM format %q: expected CycloneDX JSON", bom.BOMFormat)
+	}
+	return &bom, nil
+}
+
+// allComponents returns every component in the BOM, including nested ones.
+func (b *cdxBOM) allComponents() []cdxComponent {
+	var out []cdxComponent
+	var walk func([]cdxComponent)
+	walk = func(cs []cdxComponent) {
+		for _, c := range cs {
+			out = append(out, c)
+			walk(c.Components)
+		}
+	}
+	walk(b.Components)
+	return out
+}
+
+// inventory converts the BOM into the same shape Dependency-Track returns for
+// a project: its components and, from the embedded vulnerabilities section,
+// its findings.
+func (b *cdxBOM) inventory() ([]Component, []Finding) {
+	var components []Component
+	byRef := map[string]Component{}
+	for _, c := range b.allComponents() {
+		component := Component{Name: c.Name, Group: c.Group, Version: c.Version, PURL: c.PURL}
+		components = append(components, component)
+		if c.BOMRef != "" {
+			byRef[c.BOMRef] = component
+		}
+	}
+
+	var findings []Finding
+	for _, v := range b.Vulnerabilities {
+		severity := "UNASSIGNED"
+		if len(v.Ratings) > 0 && v.Ratings[0].Severity != "" {
+			severity = strings.ToUpper(v.Ratings[0].Severity)
+		}
+		for _, a := range v.Affects {
+			component, ok := byRef[a.Ref]
+			if !ok {
+				continue
+			}
+			findings = append(findings, Finding{
+				Component:     component,
+				Vulnerability: FindingVulnerability{VulnID: v.ID, Severity: severity},
+			})
+		}
+	}
+	return components, findings
+}
+
+// purlWithoutVersion strips the version, qualifiers and subpath from a package
+// URL, leaving the part that identifies a package across versions.
+func purlWithoutVersion(purl string) string {
+	if i := strings.IndexAny(purl, "?#"); i >= 0 {
+		purl = purl[:i]
+	}
+	if i := strings.LastIndex(purl, "@"); i > strings.LastIndex(purl, "/") {
+		purl = purl[:i]
+	}
+	return purl
+}
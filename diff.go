@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/spf13/cobra"
+)
+
+// InventoryDiff describes how a project's components and vulnerabilities
+// changed between two versions.
+type InventoryDiff struct {
+	From       string                `json:"from"`
+	To         string                `json:"to"`
+	Added      []Component           `json:"added"`
+	Removed    []Component           `json:"removed"`
+	Upgraded   []ComponentChange     `json:"upgraded"`
+	Downgraded []ComponentChange     `json:"downgraded"`
+	Introduced []VulnerabilityChange `json:"introduced"`
+	Resolved   []VulnerabilityChange `json:"resolved"`
+	// VulnerabilitiesUnknown is set when the local SBOM compared to has no
+	// vulnerabilities section; Introduced and Resolved are then nil.
+	VulnerabilitiesUnknown bool `json:"vulnerabilitiesUnknown,omitempty"`
+}
+
+// ComponentChange is a component present in both versions whose version changed.
+type ComponentChange struct {
+	Component string `json:"component"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+type VulnerabilityChange struct {
+	VulnID    string `json:"vulnId"`
+	Severity  string `json:"severity"`
+	Component string `json:"component"`
+}
+
+func newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compares the components and vulnerabilities of two project versions",
+		RunE:  runDiff,
+	}
+	s := cmd.Flags()
+	setConnectionFlags(s)
+	s.String("name", "", "Project name or env SBOM_UPLOADER_NAME")
+	s.String("from", "", "Project version to compare from")
+	s.String("to", "", "Project version to compare to")
+	s.String("sbom", "", "Path to a local CycloneDX JSON SBOM to compare to (instead of --to)")
+	s.String("format", "text", "Output format: text or json")
+	return cmd
+}
+
+func runDiff(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	cfg, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.validateConnection(); err != nil {
+		return err
+	}
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetString("to")
+	format, _ := cmd.Flags().GetString("format")
+	if cfg.Name == "" {
+		return fmt.Errorf("missing required input: name (via --name or SBOM_UPLOADER_NAME)")
+	}
+	if from == "" {
+		return fmt.Errorf("missing required input: from")
+	}
+	if (to == "") == (cfg.SBOM == "") {
+		return fmt.Errorf("exactly one of --to or --sbom is required")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q: must be text or json", format)
+	}
+
+	client, err := newDefaultRetryClient(cfg)
+	if err != nil {
+		return err
+	}
+	fromComponents, fromFindings, err := fetchInventory(ctx, cfg.URL, cfg.Name, from, client)
+	if err != nil {
+		return err
+	}
+
+	var toComponents []Component
+	var toFindings []Finding
+	vulnerabilitiesUnknown := false
+	if cfg.SBOM != "" {
+		content, err := os.ReadFile(cfg.SBOM)
+		if err != nil {
+			return fmt.Errorf("failed to read SBOM file: %w", err)
+		}
+		bom, err := parseCycloneDX(content)
+		if err != nil {
+			return err
+		}
+		toComponents, toFindings = bom.inventory()
+		// Without a vulnerabilities section every stored finding would look
+		// resolved.
+		vulnerabilitiesUnknown = bom.Vulnerabilities == nil
+		to = cfg.SBOM
+	} else {
+		toComponents, toFindings, err = fetchInventory(ctx, cfg.URL, cfg.Name, to, client)
+		if err != nil {
+			return err
+		}
+	}
+
+	d := diffInventories(fromComponents, fromFindings, toComponents, toFindings)
+	d.From, d.To = from, to
+	if vulnerabilitiesUnknown {
+		d.Introduced, d.Resolved, d.VulnerabilitiesUnknown = nil, nil, true
+	}
+	if format == "json" {
+		return writeJSON(cmd.OutOrStdout(), d)
+	}
+	writeDiffText(cmd.OutOrStdout(), d)
+	return nil
+}
+
+// fetchInventory returns the components and unsuppressed findings of a
+// project version.
+func fetchInventory(ctx context.Context, dependencyTrackUrl string, projectName string, projectVersion string, client *retryablehttp.Client) ([]Component, []Finding, error) {
+	project, err := fetchProjectSummary(ctx, dependencyTrackUrl, projectName, projectVersion, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	components, err := fetchComponents(ctx, dependencyTrackUrl, project.UUID, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	findings, err := fetchFindings(ctx, dependencyTrackUrl, project.UUID, false, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return components, findings, nil
+}
+
+func fetchComponents(ctx context.Context, dependencyTrackUrl string, projectUUID string, client *retryablehttp.Client) ([]Component, error) {
+	return fetchPaged[Component](ctx, dependencyTrackUrl, "/api/v1/component/project/"+projectUUID, nil, client)
+}
+
+// componentKey identifies a component independently of its version, so the
+// same package can be matched across two inventories.
+func componentKey(c Component) string {
+	if c.PURL != "" {
+		return purlWithoutVersion(c.PURL)
+	}
+	if c.Group != "" {
+		return c.Group + "/" + c.Name
+	}
+	return c.Name
+}
+
+// componentLabel is the human-readable form of a component in reports.
+func componentLabel(c Component) string {
+	if c.PURL != "" {
+		return c.PURL
+	}
+	return componentKey(c) + "@" + c.Version
+}
+
+func diffInventories(fromComponents []Component, fromFindings []Finding, toComponents []Component, toFindings []Finding) *InventoryDiff {
+	d := &InventoryDiff{
+		Added:      []Component{},
+		Removed:    []Component{},
+		Upgraded:   []ComponentChange{},
+		Downgraded: []ComponentChange{},
+		Introduced: []VulnerabilityChange{},
+		Resolved:   []VulnerabilityChange{},
+	}
+
+	fromByKey := groupByKey(fromComponents)
+	toByKey := groupByKey(toComponents)
+	for key, toVersions := range toByKey {
+		fromVersions, ok := fromByKey[key]
+		if !ok {
+			d.Added = append(d.Added, toVersions...)
+			continue
+		}
+		// The common case is a single version on each side; anything else
+		// (e.g. npm trees with several copies) is reported version by version.
+		if len(fromVersions) == 1 && len(toVersions) == 1 {
+			change := ComponentChange{Component: key, From: fromVersions[0].Version, To: toVersions[0].Version}
+			switch c := compareNatural(change.From, change.To); {
+			case c < 0:
+				d.Upgraded = append(d.Upgraded, change)
+			case c > 0:
+				d.Downgraded = append(d.Downgraded, change)
+			case change.From != change.To:
+				// Equivalent spellings, e.g. 1.0 and 1.0.0.
+				d.Upgraded = append(d.Upgraded, change)
+			}
+			continue
+		}
+		d.Added = append(d.Added, versionsNotIn(toVersions, fromVersions)...)
+		d.Removed = append(d.Removed, versionsNotIn(fromVersions, toVersions)...)
+	}
+	for key, fromVersions := range fromByKey {
+		if _, ok := toByKey[key]; !ok {
+			d.Removed = append(d.Removed, fromVersions...)
+		}
+	}
+
+	d.Introduced = vulnerabilitiesNotIn(toFindings, fromFindings)
+	d.Resolved = vulnerabilitiesNotIn(fromFindings, toFindings)
+
+	byLabel := func(s []Component) func(i, j int) bool {
+		return func(i, j int) bool { return componentLabel(s[i]) < componentLabel(s[j]) }
+	}
+	sort.Slice(d.Added, byLabel(d.Added))
+	sort.Slice(d.Removed, byLabel(d.Removed))
+	sort.Slice(d.Upgraded, func(i, j int) bool { return d.Upgraded[i].Component < d.Upgraded[j].Component })
+	sort.Slice(d.Downgraded, func(i, j int) bool { return d.Downgraded[i].Component < d.Downgraded[j].Component })
+	return d
+}
+
+func groupByKey(components []Component) map[string][]Component {
+	out := map[string][]Component{}
+	for _, c := range components {
+		key := componentKey(c)
+		if slices.ContainsFunc(out[key], func(o Component) bool { return o.Version == c.Version }) {
+			continue
+		}
+		out[key] = append(out[key], c)
+	}
+	return out
+}
+
+func versionsNotIn(a, b []Component) []Component {
+	var out []Component
+	for _, c := range a {
+		if !slices.ContainsFunc(b, func(o Component) bool { return o.Version == c.Version }) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// vulnerabilitiesNotIn returns the vulnerabilities in a that don't affect the
+// same component in b. A vulnerability still present after a version bump of
+// its component is not reported as resolved.
+func vulnerabilitiesNotIn(a, b []Finding) []VulnerabilityChange {
+	seen := map[string]bool{}
+	for _, f := range b {
+		seen[f.Vulnerability.VulnID+" "+componentKey(f.Component)] = true
+	}
+	out := []VulnerabilityChange{}
+	for _, f := range a {
+		key := f.Vulnerability.VulnID + " " + componentKey(f.Component)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, VulnerabilityChange{
+			VulnID:    f.Vulnerability.VulnID,
+			Severity:  f.Vulnerability.Severity,
+			Component: componentLabel(f.Component),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].VulnID != out[j].VulnID {
+			return out[i].VulnID < out[j].VulnID
+		}
+		return out[i].Component < out[j].Component
+	})
+	return out
+}
+
+func writeDiffText(w io.Writer, d *InventoryDiff) {
+	_, _ = fmt.Fprintf(w, "Comparing %s -> %s\n\n", d.From, d.To)
+	_, _ = fmt.Fprintf(w, "Components: %d added, %d removed, %d upgraded, %d downgraded\n", len(d.Added), len(d.Removed), len(d.Upgraded), len(d.Downgraded))
+	for _, c := range d.Added {
+		_, _ = fmt.Fprintf(w, "  + %s\n", componentLabel(c))
+	}
+	for _, c := range d.Removed {
+		_, _ = fmt.Fprintf(w, "  - %s\n", componentLabel(c))
+	}
+	for _, c := range d.Upgraded {
+		_, _ = fmt.Fprintf(w, "  ~ %s %s -> %s\n", c.Component, c.From, c.To)
+	}
+	for _, c := range d.Downgraded {
+		_, _ = fmt.Fprintf(w, "  ~ %s %s -> %s (downgrade)\n", c.Component, c.From, c.To)
+	}
+	if d.VulnerabilitiesUnknown {
+		_, _ = fmt.Fprintf(w, "\nVulnerabilities: not compared, %s has no vulnerabilities section\n", d.To)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\nVulnerabilities: %d introduced, %d resolved\n", len(d.Introduced), len(d.Resolved))
+	for _, v := range d.Introduced {
+		_, _ = fmt.Fprintf(w, "  + %s (%s) in %s\n", v.VulnID, v.Severity, v.Component)
+	}
+	for _, v := range d.Resolved {
+		_, _ = fmt.Fprintf(w, "  - %s (%s) in %s\n", v.VulnID, v.Severity, v.Component)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// --- diffInventories ---
+
+func TestDiffInventories_ComponentsAndVulnerabilities(t *testing.T) {
+	from := []Component{
+		{Name: "left-pad", Version: "1.0.0", PURL: "pkg:npm/left-pad@1.0.0"},
+		{Name: "lodash", Version: "4.17.20", PURL: "pkg:npm/lodash@4.17.20"},
+		{Name: "request", Version: "2.88.2", PURL: "pkg:npm/request@2.88.2"},
+	}
+	to := []Component{
+		{Name: "left-pad", Version: "1.0.0", PURL: "pkg:npm/left-pad@1.0.0"},
+		{Name: "lodash", Version: "4.17.21", PURL: "pkg:npm/lodash@4.17.21"},
+		{Name: "axios", Version: "0.21.0", PURL: "pkg:npm/axios@0.21.0"},
+	}
+	fromFindings := []Finding{
+		{Component: from[1], Vulnerability: FindingVulnerability{VulnID: "CVE-2021-23337", Severity: "HIGH"}},
+		{Component: from[2], Vulnerability: FindingVulnerability{VulnID: "CVE-2023-28155", Severity: "MEDIUM"}},
+	}
+	toFindings := []Finding{
+		{Component: to[2], Vulnerability: FindingVulnerability{VulnID: "CVE-2021-3749", Severity: "HIGH"}},
+	}
+
+	d := diffInventories(from, fromFindings, to, toFindings)
+
+	if len(d.Added) != 1 || d.Added[0].Name != "axios" {
+		t.Errorf("added: got %v, want [axios]", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Name != "request" {
+		t.Errorf("removed: got %v, want [request]", d.Removed)
+	}
+	want := ComponentChange{Component: "pkg:npm/lodash", From: "4.17.20", To: "4.17.21"}
+	if len(d.Upgraded) != 1 || d.Upgraded[0] != want {
+		t.Errorf("upgraded: got %v, want [%v]", d.Upgraded, want)
+	}
+	if len(d.Introduced) != 1 || d.Introduced[0].VulnID != "CVE-2021-3749" {
+		t.Errorf("introduced: got %v, want [CVE-2021-3749]", d.Introduced)
+	}
+	if len(d.Resolved) != 2 {
+		t.Errorf("resolved: got %v, want 2 entries", d.Resolved)
+	}
+}
+
+func TestDiffInventories_VulnerabilitySurvivingUpgradeIsUnchanged(t *testing.T) {
+	from := []Component{{Name: "lodash", Version: "4.17.19", PURL: "pkg:npm/lodash@4.17.19"}}
+	to := []Component{{Name: "lodash", Version: "4.17.20", PURL: "pkg:npm/lodash@4.17.20"}}
+	fromFindings := []Finding{{Component: from[0], Vulnerability: FindingVulnerability{VulnID: "CVE-2021-23337"}}}
+	toFindings := []Finding{{Component: to[0], Vulnerability: FindingVulnerability{VulnID: "CVE-2021-23337"}}}
+
+	d := diffInventories(from, fromFindings, to, toFindings)
+
+	if len(d.Introduced) != 0 || len(d.Resolved) != 0 {
+		t.Errorf("expected no vulnerability changes, got introduced=%v resolved=%v", d.Introduced, d.Resolved)
+	}
+}
+
+func TestDiffInventories_SplitsUpgradesFromDowngrades(t *testing.T) {
+	from := []Component{
+		{Name: "axios", Version: "1.6.0", PURL: "pkg:npm/axios@1.6.0"},
+		{Name: "lodash", Version: "4.17.9", PURL: "pkg:npm/lodash@4.17.9"},
+	}
+	to := []Component{
+		{Name: "axios", Version: "0.21.4", PURL: "pkg:npm/axios@0.21.4"},
+		{Name: "lodash", Version: "4.17.21", PURL: "pkg:npm/lodash@4.17.21"},
+	}
+
+	d := diffInventories(from, nil, to, nil)
+
+	if len(d.Upgraded) != 1 || d.Upgraded[0].Component != "pkg:npm/lodash" {
+		t.Errorf("upgraded: got %v, want [lodash]", d.Upgraded)
+	}
+	want := ComponentChange{Component: "pkg:npm/axios", From: "1.6.0", To: "0.21.4"}
+	if len(d.Downgraded) != 1 || d.Downgraded[0] != want {
+		t.Errorf("downgraded: got %v, want [%v]", d.Downgraded, want)
+	}
+}
+
+func TestDiffInventories_MultipleVersionsReportedIndividually(t *testing.T) {
+	from := []Component{
+		{Name: "debug", Version: "2.6.9", PURL: "pkg:npm/debug@2.6.9"},
+		{Name: "debug", Version: "4.3.1", PURL: "pkg:npm/debug@4.3.1"},
+	}
+	to := []Component{
+		{Name: "debug", Version: "4.3.1", PURL: "pkg:npm/debug@4.3.1"},
+		{Name: "debug", Version: "4.3.4", PURL: "pkg:npm/debug@4.3.4"},
+	}
+
+	d := diffInventories(from, nil, to, nil)
+
+	if len(d.Added) != 1 || d.Added[0].Version != "4.3.4" {
+		t.Errorf("added: got %v, want [debug@4.3.4]", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Version != "2.6.9" {
+		t.Errorf("removed: got %v, want [debug@2.6.9]", d.Removed)
+	}
+	if len(d.Upgraded) != 0 {
+		t.Errorf("upgraded: got %v, want none", d.Upgraded)
+	}
+}
+
+// --- purlWithoutVersion ---
+
+func TestPurlWithoutVersion(t *testing.T) {
+	tests := map[string]string{
+		"pkg:npm/lodash@4.17.21":                           "pkg:npm/lodash",
+		"pkg:npm/%40angular/core@16.0.0":                   "pkg:npm/%40angular/core",
+		"pkg:maven/org.apache/commons@1.0?type=jar":        "pkg:maven/org.apache/commons",
+		"pkg:golang/github.com/spf13/cobra@v1.9.1#sub/pkg": "pkg:golang/github.com/spf13/cobra",
+		"pkg:deb/debian/curl":                              "pkg:deb/debian/curl",
+	}
+	for in, want := range tests {
+		if got := purlWithoutVersion(in); got != want {
+			t.Errorf("purlWithoutVersion(%q): got %q, want %q", in, got, want)
+		}
+	}
+}
+
+// --- cdxBOM.inventory ---
+
+func TestCycloneDXInventory_MapsVulnerabilitiesToComponents(t *testing.T) {
+	bom, err := parseCycloneDX([]byte(`{
+		"bomFormat": "CycloneDX",
+		"components": [
+			{"bom-ref": "a", "name": "lodash", "version": "4.17.20", "purl": "pkg:npm/lodash@4.17.20",
+			 "components": [{"bom-ref": "b", "name": "nested", "version": "1.0.0"}]}
+		],
+		"vulnerabilities": [
+			{"id": "CVE-2021-23337", "ratings": [{"severity": "high"}], "affects": [{"ref": "a"}, {"ref": "missing"}]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	components, findings := bom.inventory()
+
+	if len(components) != 2 {
+		t.Errorf("components: got %d, want 2 (including nested)", len(components))
+	}
+	if len(findings) != 1 {
+		t.Fatalf("findings: got %d, want 1", len(findings))
+	}
+	if findings[0].Component.Name != "lodash" || findings[0].Vulnerability.Severity != "HIGH" {
+		t.Errorf("finding: got %+v", findings[0])
+	}
+}
+
+func TestParseCycloneDX_RejectsOtherFormats(t *testing.T) {
+	if _, err := parseCycloneDX([]byte(`{"spdxVersion":"SPDX-2.3"}`)); err == nil {
+		t.Error("expected error for non-CycloneDX document, got nil")
+	}
+}
+
+// --- fetchComponents ---
+
+func TestFetchComponents_FollowsPagination(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("pageNumber")
+		pages = append(pages, page)
+		w.Header().Set("X-Total-Count", fmt.Sprint(pageSize+1))
+		var batch []Component
+		if page == "1" {
+			batch = make([]Component, pageSize)
+		} else {
+			batch = []Component{{Name: "last"}}
+		}
+		_ = json.NewEncoder(w).Encode(batch)
+	}))
+	defer server.Close()
+
+	components, err := fetchComponents(context.Background(), server.URL, "abc-123", noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(components) != pageSize+1 {
+		t.Errorf("components: got %d, want %d", len(components), pageSize+1)
+	}
+	if strings.Join(pages, ",") != "1,2" {
+		t.Errorf("pages requested: got %v, want [1 2]", pages)
+	}
+}
+
+func TestFetchComponents_PagesWithoutTotalCount(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("pageNumber")
+		pages = append(pages, page)
+		batch := []Component{}
+		if page != "3" {
+			batch = make([]Component, pageSize)
+		}
+		_ = json.NewEncoder(w).Encode(batch)
+	}))
+	defer server.Close()
+
+	components, err := fetchComponents(context.Background(), server.URL, "abc-123", noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(components) != 2*pageSize || strings.Join(pages, ",") != "1,2,3" {
+		t.Errorf("got %d components from pages %v, want %d from [1 2 3]", len(components), pages, 2*pageSize)
+	}
+}
+
+// --- writeDiffText ---
+
+func TestWriteDiffText_ListsChanges(t *testing.T) {
+	d := &InventoryDiff{
+		From:       "1.2.0",
+		To:         "1.3.0",
+		Added:      []Component{{PURL: "pkg:npm/axios@0.21.0"}},
+		Upgraded:   []ComponentChange{{Component: "pkg:npm/lodash", From: "4.17.20", To: "4.17.21"}},
+		Introduced: []VulnerabilityChange{{VulnID: "CVE-2021-3749", Severity: "HIGH", Component: "pkg:npm/axios@0.21.0"}},
+	}
+	var buf bytes.Buffer
+	writeDiffText(&buf, d)
+	out := buf.String()
+
+	for _, want := range []string{
+		"Comparing 1.2.0 -> 1.3.0",
+		"+ pkg:npm/axios@0.21.0",
+		"~ pkg:npm/lodash 4.17.20 -> 4.17.21",
+		"+ CVE-2021-3749 (HIGH) in pkg:npm/axios@0.21.0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteDiffText_VulnerabilitiesUnknown(t *testing.T) {
+	d := &InventoryDiff{
+		From:                   "1.2.0",
+		To:                     "bom.json",
+		Downgraded:             []ComponentChange{{Component: "pkg:npm/axios", From: "1.6.0", To: "0.21.4"}},
+		VulnerabilitiesUnknown: true,
+	}
+	var buf bytes.Buffer
+	writeDiffText(&buf, d)
+	out := buf.String()
+
+	for _, want := range []string{
+		"0 upgraded, 1 downgraded",
+		"~ pkg:npm/axios 1.6.0 -> 0.21.4 (downgrade)",
+		"Vulnerabilities: not compared, bom.json has no vulnerabilities section",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "resolved") {
+		t.Errorf("expected no resolved vulnerabilities:\n%s", out)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bomVariants = []string{"inventory", "withVulnerabilities", "vdr"}
+	bomFormats  = []string{"json", "xml"}
+)
+
+func newDownloadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "download",
+		Short: "Downloads the BOM Dependency-Track stored for a project version",
+		RunE:  runDownload,
+	}
+	s := cmd.Flags()
+	setConnectionFlags(s)
+	s.String("name", "", "Project name or env SBOM_UPLOADER_NAME")
+	s.String("version", "", "Project version or env SBOM_UPLOADER_VERSION")
+	s.String("uuid", "", "Project UUID (instead of --name and --version)")
+	s.String("variant", "inventory", "BOM variant: "+strings.Join(bomVariants, ", "))
+	s.String("format", "json", "BOM format: "+strings.Join(bomFormats, ", "))
+	s.StringP("output", "o", "", "Path to write the BOM to (default bom.<format>)")
+	return cmd
+}
+
+func runDownload(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	cfg, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.validateConnection(); err != nil {
+		return err
+	}
+	uuid, _ := cmd.Flags().GetString("uuid")
+	variant, _ := cmd.Flags().GetString("variant")
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	if !slices.Contains(bomVariants, variant) {
+		return fmt.Errorf("invalid variant %q: must be one of %s", variant, strings.Join(bomVariants, ", "))
+	}
+	if !slices.Contains(bomFormats, format) {
+		return fmt.Errorf("invalid format %q: must be one of %s", format, strings.Join(bomFormats, ", "))
+	}
+	if output == "" {
+		output = "bom." + format
+	}
+
+	client, err := newDefaultRetryClient(cfg)
+	if err != nil {
+		return err
+	}
+	uuid, err = resolveProjectUUID(ctx, cfg, uuid, client)
+	if err != nil {
+		return err
+	}
+
+	bom, err := fetchBom(ctx, cfg.URL, uuid, variant, format, client)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, bom, 0o644); err != nil {
+		return fmt.Errorf("failed to write BOM file: %w", err)
+	}
+	slog.Info("BOM written.", "variant", variant, "format", format, "uuid", uuid, "path", output, "bytes", len(bom))
+	return nil
+}
+
+func fetchBom(ctx context.Context, dependencyTrackUrl string, projectUUID string, variant string, format string, client *retryablehttp.Client) ([]byte, error) {
+	slog.Info("Downloading BOM...", "variant", variant, "uuid", projectUUID)
+	query := url.Values{"variant": {variant}, "format": {format}}
+	url := fmt.Sprintf("%s/api/v1/bom/cyclonedx/project/%s?%s", strings.TrimRight(dependencyTrackUrl, "/"), projectUUID, query.Encode())
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("BOM download failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read BOM response: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// --- fetchBom ---
+
+func TestFetchBom_SendsVariantAndFormat(t *testing.T) {
+	var gotPath, gotVariant, gotFormat string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVariant = r.URL.Query().Get("variant")
+		gotFormat = r.URL.Query().Get("format")
+		_, _ = io.WriteString(w, `<bom/>`)
+	}))
+	defer server.Close()
+
+	got, err := fetchBom(context.Background(), server.URL, "abc-123", "vdr", "xml", noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != `<bom/>` {
+		t.Errorf("body: got %q", got)
+	}
+	if gotPath != "/api/v1/bom/cyclonedx/project/abc-123" {
+		t.Errorf("path: got %q", gotPath)
+	}
+	if gotVariant != "vdr" {
+		t.Errorf("variant: got %q, want %q", gotVariant, "vdr")
+	}
+	if gotFormat != "xml" {
+		t.Errorf("format: got %q, want %q", gotFormat, "xml")
+	}
+}
+
+func TestFetchBom_NonOKStatusReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if _, err := fetchBom(context.Background(), server.URL, "abc-123", "inventory", "json", noRetryClient()); err == nil {
+		t.Error("expected error for HTTP 404, got nil")
+	}
+}
+
+// --- resolveProjectUUID ---
+
+func TestResolveProjectUUID_PrefersExplicitUUID(t *testing.T) {
+	uuid, err := resolveProjectUUID(context.Background(), &Config{}, "given-uuid", noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if uuid != "given-uuid" {
+		t.Errorf("uuid: got %q, want %q", uuid, "given-uuid")
+	}
+}
+
+func TestResolveProjectUUID_LooksUpByNameAndVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") != "my-project" || r.URL.Query().Get("version") != "1.0.0" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		_, _ = io.WriteString(w, `{"uuid":"looked-up-uuid","name":"my-project"}`)
+	}))
+	defer server.Close()
+
+	cfg := &Config{URL: server.URL, APIKey: "test-key", Name: "my-project", Version: "1.0.0"}
+	uuid, err := resolveProjectUUID(context.Background(), cfg, "", noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if uuid != "looked-up-uuid" {
+		t.Errorf("uuid: got %q, want %q", uuid, "looked-up-uuid")
+	}
+}
+
+func TestResolveProjectUUID_MissingInputsReturnsError(t *testing.T) {
+	if _, err := resolveProjectUUID(context.Background(), &Config{Name: "my-project"}, "", noRetryClient()); err == nil {
+		t.Error("expected error when version and uuid are missing, got nil")
+	}
+}
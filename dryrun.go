@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// dryRunProjectUUID is returned for projects "created" and teams and rules
+// found during a dry run.
+const dryRunProjectUUID = "00000000-0000-0000-0000-000000000000"
+
+// newDryRunClient returns a client that prints every request to w instead of
+// sending it. The requests are built by the same code as a real run; only the
+// credential is replaced by a placeholder, so no credential helper or token
+// exchange runs either.
+func newDryRunClient(cfg *Config, w io.Writer) *retryablehttp.Client {
+	scheme := cfg.AuthScheme
+	if cfg.BearerToken != "" || cfg.OIDCTokenURL != "" {
+		scheme = authSchemeBearer
+	}
+	c := retryablehttp.NewClient()
+	c.HTTPClient.Transport = &authTransport{
+		base: &dryRunTransport{w: w, teams: cfg.Teams, rules: cfg.NotificationRules},
+		auth: newStaticAuthenticator(newCredential(scheme, redacted)),
+	}
+	c.RetryMax = 0
+	c.Logger = slog.Default()
+	return c
+}
+
+// dryRunTransport prints requests and answers them with canned responses that
+// let the upload workflow continue. A parent lookup is answered with 404, so
+// the request creating the parent is shown too, and the --team and
+// --notification-rule names are answered as existing teams and rules.
+type dryRunTransport struct {
+	w     io.Writer
+	teams []string
+	rules []string
+	count int
+	// uploaded is set once the BOM is uploaded, which creates the project.
+	uploaded bool
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count++
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d. %s %s\n", t.count, req.Method, req.URL.RequestURI())
+	if strings.HasSuffix(req.URL.Path, "/api/v1/project") && req.Method == http.MethodPut {
+		sb.WriteString("   (only if the parent project doesn't exist)\n")
+	}
+	for _, name := range []string{"X-Api-Key", "Authorization", "Content-Type"} {
+		if v := req.Header.Get(name); v != "" {
+			fmt.Fprintf(&sb, "   %s: %s\n", name, v)
+		}
+	}
+	writeDryRunBody(&sb, req.Header.Get("Content-Type"), body)
+	sb.WriteString("\n")
+	if _, err := io.WriteString(t.w, secrets.redact(sb.String())); err != nil {
+		return nil, err
+	}
+
+	status, respBody := http.StatusOK, "{}"
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/api/v1/project/lookup"):
+		status = http.StatusNotFound
+		if t.uploaded && req.URL.Query().Has("version") {
+			status, respBody = http.StatusOK, fmt.Sprintf(`{"uuid":%q}`, dryRunProjectUUID)
+		}
+	case strings.HasSuffix(req.URL.Path, "/api/v1/team"):
+		teams := []Team{}
+		for _, name := range t.teams {
+			teams = append(teams, Team{UUID: dryRunProjectUUID, Name: name})
+		}
+		b, _ := json.Marshal(teams)
+		respBody = string(b)
+	case strings.HasSuffix(req.URL.Path, "/api/v1/notification/rule"):
+		rules := []NotificationRule{}
+		for _, name := range t.rules {
+			rules = append(rules, NotificationRule{UUID: dryRunProjectUUID, Name: name})
+		}
+		b, _ := json.Marshal(rules)
+		respBody = string(b)
+	case strings.HasSuffix(req.URL.Path, "/api/v1/project"):
+		status, respBody = http.StatusCreated, fmt.Sprintf(`{"uuid":%q}`, dryRunProjectUUID)
+	case strings.HasSuffix(req.URL.Path, "/api/v1/bom"), strings.HasSuffix(req.URL.Path, "/api/v1/vex"):
+		t.uploaded = true
+		respBody = `{"token":"dry-run"}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(respBody)),
+		Request:    req,
+	}, nil
+}
+
+// writeDryRunBody prints JSON bodies indented and multipart forms field by
+// field, with file parts reduced to their name and size.
+func writeDryRunBody(sb *strings.Builder, contentType string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json":
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, body, "   ", "  "); err == nil {
+			fmt.Fprintf(sb, "   %s\n", indented.String())
+			return
+		}
+	case strings.HasPrefix(mediaType, "multipart/"):
+		r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := r.NextPart()
+			if err != nil {
+				return
+			}
+			value, _ := io.ReadAll(part)
+			if part.FileName() != "" {
+				fmt.Fprintf(sb, "   %s: <file %s, %d bytes>\n", part.FormName(), part.FileName(), len(value))
+			} else {
+				fmt.Fprintf(sb, "   %s: %s\n", part.FormName(), value)
+			}
+		}
+	}
+	fmt.Fprintf(sb, "   <%d bytes>\n", len(body))
+}
+
+// validateSbom checks that content is a CycloneDX BOM in JSON or XML, the
+// formats Dependency-Track accepts.
+func validateSbom(content []byte) error {
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		var root struct {
+			XMLName xml.Name
+		}
+		if err := xml.Unmarshal(trimmed, &root); err != nil {
+			return fmt.Errorf("failed to parse CycloneDX XML: %w", err)
+		}
+		if root.XMLName.Local != "bom" || !strings.HasPrefix(root.XMLName.Space, "http://cyclonedx.org/schema/bom/") {
+			return errors.New("unsupported SBOM format: expected a CycloneDX <bom> document")
+		}
+		return nil
+	}
+	_, err := parseCycloneDX(trimmed)
+	return err
+}
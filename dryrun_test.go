@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDryRun_PrintsRequestsWithoutSending(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	cfg := &Config{APIKey: "dry-run-secret-key", AuthScheme: authSchemeAPIKey}
+	client := newDryRunClient(cfg, &out)
+	ctx := context.Background()
+	if _, _, err := ensureParentExists(ctx, server.URL, "my-parent", "a,b", client); err != nil {
+		t.Fatalf("ensureParentExists: %v", err)
+	}
+	token, err := uploadSbom(ctx, server.URL, "my-project", "my-parent", "1.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "a,b", true, client)
+	if err != nil {
+		t.Fatalf("uploadSbom: %v", err)
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("expected no requests to reach the server, got %d", calls.Load())
+	}
+	if token != "dry-run" {
+		t.Errorf("token: got %q, want %q", token, "dry-run")
+	}
+	got := out.String()
+	for _, want := range []string{
+		"1. GET /api/v1/project/lookup?name=my-parent",
+		"2. PUT /api/v1/project\n   (only if the parent project doesn't exist)",
+		`"collectionLogic": "AGGREGATE_LATEST_VERSION_CHILDREN"`,
+		"3. POST /api/v1/bom",
+		"bom: <file sbom.json, 25 bytes>",
+		"projectName: my-project",
+		"isLatest: true",
+		"X-Api-Key: [REDACTED]",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "dry-run-secret-key") {
+		t.Errorf("output contains the API key:\n%s", got)
+	}
+}
+
+func TestDryRun_BearerPlaceholder(t *testing.T) {
+	var out bytes.Buffer
+	client := newDryRunClient(&Config{OIDCTokenURL: "https://sts.example.com/token", AuthScheme: authSchemeAPIKey}, &out)
+	if _, _, err := ensureParentExists(context.Background(), "http://dtrack.invalid", "my-parent", "", client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Authorization: Bearer [REDACTED]") {
+		t.Errorf("expected bearer placeholder:\n%s", out.String())
+	}
+}
+
+func TestValidateSbom(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"CycloneDX JSON", `{"bomFormat":"CycloneDX","specVersion":"1.5"}`, false},
+		{"CycloneDX XML", `<?xml version="1.0"?><bom xmlns="http://cyclonedx.org/schema/bom/1.5"></bom>`, false},
+		{"SPDX JSON", `{"spdxVersion":"SPDX-2.3"}`, true},
+		{"other XML", `<project xmlns="http://maven.apache.org/POM/4.0.0"></project>`, true},
+		{"not a BOM", `THIS IS NOT JSON`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSbom([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSbom: got %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// formatEPSS renders an EPSS probability, or "-" if it is unknown.
+func formatEPSS(score float64) string {
+	if score <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.4f", score)
+}
+
+// epssViolations returns the unsuppressed findings whose EPSS score is above
+// threshold and whose severity is at least minSeverity (any severity if
+// empty), most likely exploited first.
+func epssViolations(findings []Finding, threshold float64, minSeverity string) []Finding {
+	out := []Finding{}
+	for _, f := range findings {
+		if f.Analysis.IsSuppressed || f.Vulnerability.EPSSScore <= threshold {
+			continue
+		}
+		if minSeverity != "" && severityRank(f.Vulnerability.Severity) < severityRank(minSeverity) {
+			continue
+		}
+		out = append(out, f)
+	}
+	sortFindingsByEPSS(out)
+	return out
+}
+
+// epssGateError describes the findings that failed --fail-on-epss.
+func epssGateError(violations []Finding, threshold float64, minSeverity string) error {
+	what := "findings"
+	if len(violations) == 1 {
+		what = "finding"
+	}
+	if minSeverity != "" {
+		what += " of severity " + strings.ToUpper(minSeverity) + " or higher"
+	}
+	return fmt.Errorf("%d unsuppressed %s with an EPSS score above %g", len(violations), what, threshold)
+}
+
+func writeEPSSReport(w io.Writer, findings []Finding) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "EPSS\tPERCENTILE\tSEVERITY\tVULNERABILITY\tCOMPONENT\tVERSION")
+	for _, f := range findings {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatEPSS(f.Vulnerability.EPSSScore), formatEPSS(f.Vulnerability.EPSSPercentile), f.Vulnerability.Severity,
+			f.Vulnerability.VulnID, componentKey(f.Component), f.Component.Version)
+	}
+	_ = tw.Flush()
+}
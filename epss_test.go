@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func epssFinding(vulnID string, severity string, epss float64) Finding {
+	return Finding{
+		Component:     Component{Name: "lib-" + vulnID, Version: "1.0.0"},
+		Vulnerability: FindingVulnerability{VulnID: vulnID, Severity: severity, EPSSScore: epss},
+	}
+}
+
+func vulnIDs(findings []Finding) string {
+	var ids []string
+	for _, f := range findings {
+		ids = append(ids, f.Vulnerability.VulnID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestEPSSViolations(t *testing.T) {
+	suppressed := epssFinding("CVE-5", "CRITICAL", 0.9)
+	suppressed.Analysis.IsSuppressed = true
+	findings := []Finding{
+		epssFinding("CVE-1", "CRITICAL", 0.05),
+		epssFinding("CVE-2", "MEDIUM", 0.3),
+		epssFinding("CVE-3", "HIGH", 0.7),
+		epssFinding("CVE-4", "LOW", 0),
+		suppressed,
+	}
+
+	if got := vulnIDs(epssViolations(findings, 0.1, "")); got != "CVE-3,CVE-2" {
+		t.Errorf("got %s, want CVE-3,CVE-2", got)
+	}
+	if got := vulnIDs(epssViolations(findings, 0.1, "high")); got != "CVE-3" {
+		t.Errorf("with a minimum severity: got %s, want CVE-3", got)
+	}
+	if got := epssViolations(findings, 0.9, ""); len(got) != 0 {
+		t.Errorf("expected no violations at the suppressed finding's score, got %s", vulnIDs(got))
+	}
+}
+
+func TestSortFindingsByEPSS(t *testing.T) {
+	findings := []Finding{
+		epssFinding("CVE-1", "CRITICAL", 0),
+		epssFinding("CVE-2", "LOW", 0.2),
+		epssFinding("CVE-3", "HIGH", 0),
+		epssFinding("CVE-4", "MEDIUM", 0.6),
+	}
+	sortFindingsByEPSS(findings)
+	if got := vulnIDs(findings); got != "CVE-4,CVE-2,CVE-1,CVE-3" {
+		t.Errorf("expected EPSS order, then severity, got %s", got)
+	}
+}
+
+func TestEPSSGateError(t *testing.T) {
+	err := epssGateError([]Finding{epssFinding("CVE-1", "HIGH", 0.5)}, 0.1, "high")
+	if want := "1 unsuppressed finding of severity HIGH or higher with an EPSS score above 0.1"; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+func TestWriteEPSSReport(t *testing.T) {
+	f := epssFinding("CVE-2021-44228", "CRITICAL", 0.97565)
+	f.Vulnerability.EPSSPercentile = 0.99996
+	var out bytes.Buffer
+	writeEPSSReport(&out, []Finding{f})
+
+	want := "EPSS    PERCENTILE  SEVERITY  VULNERABILITY   COMPONENT           VERSION\n" +
+		"0.9757  1.0000      CRITICAL  CVE-2021-44228  lib-CVE-2021-44228  1.0.0\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,69 @@
+package fakedtrack
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+type Team struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+type aclMapping struct {
+	Team    string `json:"team"`
+	Project string `json:"project"`
+}
+
+// AddTeam creates a team and returns its UUID.
+func (s *Server) AddTeam(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &Team{UUID: newUUID(), Name: name}
+	s.teams[t.UUID] = t
+	return t.UUID
+}
+
+// ACL returns the UUIDs of the teams with access to a project.
+func (s *Server) ACL(projectUUID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.acl[projectUUID])
+}
+
+func (s *Server) listTeams(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	out := []Team{}
+	for _, t := range s.teams {
+		out = append(out, *t)
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) addACLMapping(w http.ResponseWriter, r *http.Request) {
+	var m aclMapping
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "Invalid ACL mapping", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.teams[m.Team]; !ok {
+		http.Error(w, "The UUID of the team could not be found.", http.StatusNotFound)
+		return
+	}
+	if _, ok := s.projects[m.Project]; !ok {
+		http.Error(w, "The UUID of the project could not be found.", http.StatusNotFound)
+		return
+	}
+	if slices.Contains(s.acl[m.Project], m.Team) {
+		http.Error(w, "A mapping with the same team and project already exists.", http.StatusConflict)
+		return
+	}
+	s.acl[m.Project] = append(s.acl[m.Project], m.Team)
+	w.WriteHeader(http.StatusOK)
+}
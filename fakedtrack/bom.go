@@ -0,0 +1,110 @@
+package fakedtrack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+type cdxComponent struct {
+	BOMRef     string         `json:"bom-ref"`
+	Name       string         `json:"name"`
+	Group      string         `json:"group"`
+	Version    string         `json:"version"`
+	PURL       string         `json:"purl"`
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxBOM struct {
+	BOMFormat       string         `json:"bomFormat"`
+	Components      []cdxComponent `json:"components"`
+	Vulnerabilities []struct {
+		ID     string `json:"id"`
+		Source struct {
+			Name string `json:"name"`
+		} `json:"source"`
+		Ratings []struct {
+			Severity string  `json:"severity"`
+			Score    float64 `json:"score"`
+			Method   string  `json:"method"`
+		} `json:"ratings"`
+		References []struct {
+			ID string `json:"id"`
+		} `json:"references"`
+		Affects []struct {
+			Ref string `json:"ref"`
+		} `json:"affects"`
+	} `json:"vulnerabilities"`
+}
+
+// parseBOM extracts the components of a CycloneDX JSON BOM, and findings for
+// the vulnerabilities it declares. XML BOMs are accepted but yield an empty
+// inventory.
+func parseBOM(content []byte) ([]Component, []Finding, error) {
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("<")) {
+		return []Component{}, []Finding{}, nil
+	}
+	var bom cdxBOM
+	if err := json.Unmarshal(content, &bom); err != nil {
+		return nil, nil, errors.New("BOM is neither valid JSON nor XML")
+	}
+	if bom.BOMFormat != "CycloneDX" {
+		return nil, nil, errors.New("unsupported BOM format")
+	}
+
+	components := []Component{}
+	byRef := map[string]Component{}
+	var walk func([]cdxComponent)
+	walk = func(cs []cdxComponent) {
+		for _, c := range cs {
+			component := Component{UUID: newUUID(), Name: c.Name, Group: c.Group, Version: c.Version, PURL: c.PURL}
+			components = append(components, component)
+			if c.BOMRef != "" {
+				byRef[c.BOMRef] = component
+			}
+			walk(c.Components)
+		}
+	}
+	walk(bom.Components)
+
+	findings := []Finding{}
+	for _, v := range bom.Vulnerabilities {
+		severity := "UNASSIGNED"
+		if len(v.Ratings) > 0 && v.Ratings[0].Severity != "" {
+			severity = strings.ToUpper(v.Ratings[0].Severity)
+		}
+		source := v.Source.Name
+		if source == "" {
+			source = "NVD"
+		}
+		vuln := Vulnerability{UUID: newUUID(), VulnID: v.ID, Source: source, Severity: severity}
+		for _, r := range v.Ratings {
+			if strings.HasPrefix(r.Method, "CVSSv3") {
+				vuln.CVSSV3BaseScore = max(vuln.CVSSV3BaseScore, r.Score)
+			}
+		}
+		// Like Dependency-Track, references to other advisories become aliases.
+		for _, ref := range v.References {
+			alias := Alias{}
+			for _, id := range []string{v.ID, ref.ID} {
+				switch {
+				case strings.HasPrefix(id, "CVE-"):
+					alias.CveID = id
+				case strings.HasPrefix(id, "GHSA-"):
+					alias.GhsaID = id
+				}
+			}
+			if alias != (Alias{}) {
+				vuln.Aliases = append(vuln.Aliases, alias)
+			}
+		}
+		for _, a := range v.Affects {
+			if c, ok := byRef[a.Ref]; ok {
+				findings = append(findings, Finding{Component: c, Vulnerability: vuln})
+			}
+		}
+	}
+	return components, findings, nil
+}
@@ -0,0 +1,93 @@
+package fakedtrack
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Failure makes matching requests fail with Status instead of being handled.
+type Failure struct {
+	// Method matches the request method; empty matches any method.
+	Method string
+	// Path matches requests whose path starts with it; empty matches any path.
+	Path string
+	// Status is the response status code.
+	Status int
+	// Count is how many requests fail before the failure is used up; 0 fails
+	// every matching request.
+	Count int
+	// RetryAfter, if set, is sent as the Retry-After header.
+	RetryAfter string
+	// Body is the response body; it defaults to the status text.
+	Body string
+}
+
+// InjectFailure adds f. Failures are matched in the order they were added.
+func (s *Server) InjectFailure(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &f)
+}
+
+// takeFailure returns the first failure matching r and uses it up by one.
+// The caller must hold s.mu.
+func (s *Server) takeFailure(r *http.Request) *Failure {
+	for i, f := range s.failures {
+		if f.Method != "" && !strings.EqualFold(f.Method, r.Method) {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, f.Path) {
+			continue
+		}
+		if f.Count > 0 {
+			f.Count--
+			if f.Count == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (f *Failure) write(w http.ResponseWriter) {
+	if f.RetryAfter != "" {
+		w.Header().Set("Retry-After", f.RetryAfter)
+	}
+	body := f.Body
+	if body == "" {
+		body = http.StatusText(f.Status)
+	}
+	http.Error(w, body, f.Status)
+}
+
+// ParseFailure parses a failure spec of the form
+//
+//	[METHOD ]PATH=STATUS[xCOUNT]
+//
+// e.g. "POST /api/v1/bom=503x2" fails the first two BOM uploads with 503.
+func ParseFailure(spec string) (Failure, error) {
+	target, result, ok := strings.Cut(spec, "=")
+	if !ok {
+		return Failure{}, fmt.Errorf("invalid failure %q: expected [METHOD ]PATH=STATUS[xCOUNT]", spec)
+	}
+	var f Failure
+	if method, path, ok := strings.Cut(strings.TrimSpace(target), " "); ok {
+		f.Method, f.Path = strings.ToUpper(method), strings.TrimSpace(path)
+	} else {
+		f.Path = method
+	}
+	status, count, hasCount := strings.Cut(result, "x")
+	var err error
+	if f.Status, err = strconv.Atoi(status); err != nil || f.Status < 100 || f.Status > 599 {
+		return Failure{}, fmt.Errorf("invalid failure %q: status must be an HTTP status code", spec)
+	}
+	if hasCount {
+		if f.Count, err = strconv.Atoi(count); err != nil || f.Count < 1 {
+			return Failure{}, fmt.Errorf("invalid failure %q: count must be a positive number", spec)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,584 @@
+// Package fakedtrack is an in-memory fake of the subset of the
+// Dependency-Track REST API used by upload-sbom-go: project lookup and
+// creation, BOM and VEX upload, token polling, metrics, components, findings,
+// policy violations, team access (ACL mappings) and notification rules. It is
+// meant for offline end-to-end tests, either in-process via
+// net/http/httptest or standalone via the serve-fake command.
+//
+// Uploaded BOMs are parsed for their components and vulnerabilities, which
+// become the project's components, findings and metrics once the upload's
+// token has finished processing. Failures can be injected per endpoint to
+// exercise retries and error handling.
+package fakedtrack
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type ParentRef struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+type Project struct {
+	UUID            string     `json:"uuid"`
+	Name            string     `json:"name"`
+	Version         string     `json:"version,omitempty"`
+	Classifier      string     `json:"classifier,omitempty"`
+	CollectionLogic string     `json:"collectionLogic,omitempty"`
+	Active          bool       `json:"active"`
+	IsLatest        bool       `json:"isLatest"`
+	Tags            []Tag      `json:"tags"`
+	Parent          *ParentRef `json:"parent,omitempty"`
+	Metrics         *Metrics   `json:"metrics,omitempty"`
+}
+
+type Metrics struct {
+	Critical        int `json:"critical"`
+	High            int `json:"high"`
+	Medium          int `json:"medium"`
+	Low             int `json:"low"`
+	Unassigned      int `json:"unassigned"`
+	Vulnerabilities int `json:"vulnerabilities"`
+	Components      int `json:"components"`
+	// InheritedRiskScore uses Dependency-Track's weights: 10 per critical,
+	// 5 per high or unassigned, 3 per medium and 1 per low vulnerability.
+	InheritedRiskScore float64 `json:"inheritedRiskScore"`
+	FirstOccurrence    int64   `json:"firstOccurrence"`
+	LastOccurrence     int64   `json:"lastOccurrence"`
+}
+
+type Component struct {
+	UUID    string `json:"uuid"`
+	Name    string `json:"name"`
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+type Vulnerability struct {
+	UUID            string  `json:"uuid"`
+	VulnID          string  `json:"vulnId"`
+	Source          string  `json:"source"`
+	Severity        string  `json:"severity"`
+	CVSSV3BaseScore float64 `json:"cvssV3BaseScore,omitempty"`
+	EPSSScore       float64 `json:"epssScore,omitempty"`
+	EPSSPercentile  float64 `json:"epssPercentile,omitempty"`
+	Aliases         []Alias `json:"aliases,omitempty"`
+}
+
+type Alias struct {
+	CveID  string `json:"cveId,omitempty"`
+	GhsaID string `json:"ghsaId,omitempty"`
+}
+
+type Analysis struct {
+	State        string `json:"state,omitempty"`
+	IsSuppressed bool   `json:"isSuppressed"`
+}
+
+type Finding struct {
+	Component     Component     `json:"component"`
+	Vulnerability Vulnerability `json:"vulnerability"`
+	Analysis      Analysis      `json:"analysis"`
+}
+
+type Policy struct {
+	Name           string `json:"name"`
+	ViolationState string `json:"violationState"`
+}
+
+type PolicyCondition struct {
+	Policy Policy `json:"policy"`
+}
+
+type Violation struct {
+	UUID            string          `json:"uuid"`
+	Type            string          `json:"type"`
+	Component       Component       `json:"component"`
+	PolicyCondition PolicyCondition `json:"policyCondition"`
+}
+
+// upload is a BOM or VEX upload identified by its token.
+type upload struct {
+	project    string
+	polls      int
+	components []Component
+	findings   []Finding
+}
+
+// Server is the fake Dependency-Track instance. It implements http.Handler.
+type Server struct {
+	apiKey          string
+	processingPolls int
+
+	mu         sync.Mutex
+	mux        *http.ServeMux
+	projects   map[string]*Project
+	components map[string][]Component
+	findings   map[string][]Finding
+	violations map[string][]Violation
+	uploads    map[string]*upload
+	teams      map[string]*Team
+	acl        map[string][]string
+	rules      map[string]*NotificationRule
+	epss       map[string][2]float64
+	failures   []*Failure
+	requests   []string
+}
+
+type Option func(*Server)
+
+// WithAPIKey requires every request to carry key, as X-Api-Key or as a bearer
+// token. Without it, any credential (or none) is accepted.
+func WithAPIKey(key string) Option {
+	return func(s *Server) { s.apiKey = key }
+}
+
+// WithProcessingPolls makes each upload token report processing for n polls
+// before it completes.
+func WithProcessingPolls(n int) Option {
+	return func(s *Server) { s.processingPolls = n }
+}
+
+func New(opts ...Option) *Server {
+	s := &Server{
+		mux:        http.NewServeMux(),
+		projects:   map[string]*Project{},
+		components: map[string][]Component{},
+		findings:   map[string][]Finding{},
+		violations: map[string][]Violation{},
+		uploads:    map[string]*upload{},
+		teams:      map[string]*Team{},
+		acl:        map[string][]string{},
+		rules:      map[string]*NotificationRule{},
+		epss:       map[string][2]float64{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("GET /api/v1/project", s.listProjects)
+	s.mux.HandleFunc("PUT /api/v1/project", s.createProject)
+	s.mux.HandleFunc("GET /api/v1/project/lookup", s.lookupProject)
+	s.mux.HandleFunc("GET /api/v1/project/tag/{tag}", s.listProjects)
+	s.mux.HandleFunc("GET /api/v1/project/{uuid}", s.getProject)
+	s.mux.HandleFunc("POST /api/v1/bom", s.uploadBom)
+	s.mux.HandleFunc("GET /api/v1/bom/token/{token}", s.pollToken)
+	s.mux.HandleFunc("POST /api/v1/vex", s.uploadVex)
+	s.mux.HandleFunc("GET /api/v1/metrics/project/{uuid}/refresh", s.refreshMetrics)
+	s.mux.HandleFunc("GET /api/v1/metrics/project/{uuid}/current", s.currentMetrics)
+	s.mux.HandleFunc("GET /api/v1/component/project/{uuid}", s.listComponents)
+	s.mux.HandleFunc("GET /api/v1/finding/project/{uuid}", s.listFindings)
+	s.mux.HandleFunc("GET /api/v1/violation/project/{uuid}", s.listViolations)
+	s.mux.HandleFunc("GET /api/v1/team", s.listTeams)
+	s.mux.HandleFunc("PUT /api/v1/acl/mapping", s.addACLMapping)
+	s.mux.HandleFunc("GET /api/v1/notification/rule", s.listNotificationRules)
+	s.mux.HandleFunc("POST /api/v1/notification/rule/{rule}/project/{project}", s.addNotificationRuleProject)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	failure := s.takeFailure(r)
+	s.mu.Unlock()
+
+	if failure != nil {
+		failure.write(w)
+		return
+	}
+	if s.apiKey != "" && r.Header.Get("X-Api-Key") != s.apiKey && r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Requests returns "METHOD /path" for every request received so far,
+// including those answered with an injected failure.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// AddProject stores p, assigning a UUID if it has none, and returns the UUID.
+func (s *Server) AddProject(p Project) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.UUID == "" {
+		p.UUID = newUUID()
+	}
+	if p.Tags == nil {
+		p.Tags = []Tag{}
+	}
+	s.projects[p.UUID] = &p
+	return p.UUID
+}
+
+// Project returns the project with the given name and version, if any.
+func (s *Server) Project(name string, version string) (Project, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.find(name, version)
+	if p == nil {
+		return Project{}, false
+	}
+	return *p, true
+}
+
+// AddViolation adds a policy violation to a project.
+func (s *Server) AddViolation(projectUUID string, v Violation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v.UUID == "" {
+		v.UUID = newUUID()
+	}
+	s.violations[projectUUID] = append(s.violations[projectUUID], v)
+}
+
+// SetAnalysis records an analysis decision for every finding of vulnID in a
+// project, e.g. to suppress it.
+func (s *Server) SetAnalysis(projectUUID string, vulnID string, a Analysis) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.findings[projectUUID] {
+		if f.Vulnerability.VulnID == vulnID {
+			s.findings[projectUUID][i].Analysis = a
+		}
+	}
+}
+
+// SetEPSS sets the EPSS score and percentile reported on every finding of
+// vulnID, like Dependency-Track's mirror of the FIRST EPSS feed.
+func (s *Server) SetEPSS(vulnID string, score float64, percentile float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.epss[vulnID] = [2]float64{score, percentile}
+}
+
+func (s *Server) find(name string, version string) *Project {
+	for _, p := range s.projects {
+		if p.Name == name && p.Version == version {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Server) lookupProject(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.find(r.URL.Query().Get("name"), r.URL.Query().Get("version"))
+	if p == nil {
+		http.Error(w, "The project could not be found.", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) getProject(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[r.PathValue("uuid")]
+	if !ok {
+		http.Error(w, "The project could not be found.", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	var p Project
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Name == "" {
+		http.Error(w, "Invalid project", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(p.Name, p.Version) != nil {
+		http.Error(w, "A project with the specified name already exists.", http.StatusConflict)
+		return
+	}
+	p.UUID = newUUID()
+	p.Active = true
+	p.Metrics = nil
+	if p.Tags == nil {
+		p.Tags = []Tag{}
+	}
+	s.projects[p.UUID] = &p
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	tag := r.PathValue("tag")
+	name := r.URL.Query().Get("name")
+	searchText := strings.ToLower(r.URL.Query().Get("searchText"))
+	excludeInactive := r.URL.Query().Get("excludeInactive") == "true"
+
+	s.mu.Lock()
+	var out []Project
+	for _, p := range s.projects {
+		if tag != "" && !slices.ContainsFunc(p.Tags, func(t Tag) bool { return strings.EqualFold(t.Name, tag) }) {
+			continue
+		}
+		if name != "" && p.Name != name {
+			continue
+		}
+		if searchText != "" && !strings.Contains(strings.ToLower(p.Name), searchText) {
+			continue
+		}
+		if excludeInactive && !p.Active {
+			continue
+		}
+		out = append(out, *p)
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name+" "+out[i].Version < out[j].Name+" "+out[j].Version })
+	writePage(w, r, out)
+}
+
+func (s *Server) uploadBom(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	content, ok := formFile(r, "bom")
+	if !ok {
+		http.Error(w, "Missing bom", http.StatusBadRequest)
+		return
+	}
+	components, findings, err := parseBOM(content)
+	if err != nil {
+		http.Error(w, "The uploaded BOM is invalid: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name, version := r.FormValue("projectName"), r.FormValue("projectVersion")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.find(name, version)
+	if p == nil {
+		if r.FormValue("autoCreate") != "true" {
+			http.Error(w, "The project could not be found.", http.StatusNotFound)
+			return
+		}
+		p = &Project{UUID: newUUID(), Name: name, Version: version, Active: true, Tags: []Tag{}}
+		for _, t := range strings.Split(r.FormValue("tags"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				p.Tags = append(p.Tags, Tag{Name: t})
+			}
+		}
+		if parentName := r.FormValue("parentName"); parentName != "" {
+			parent := s.find(parentName, "")
+			if parent == nil {
+				http.Error(w, "The parent component could not be found.", http.StatusNotFound)
+				return
+			}
+			p.Parent = &ParentRef{UUID: parent.UUID, Name: parent.Name}
+		}
+		s.projects[p.UUID] = p
+	}
+	p.IsLatest = r.FormValue("isLatest") == "true"
+
+	token := newUUID()
+	s.uploads[token] = &upload{project: p.UUID, components: components, findings: findings}
+	writeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
+
+func (s *Server) uploadVex(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	if _, ok := formFile(r, "vex"); !ok {
+		http.Error(w, "Missing vex", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.find(r.FormValue("projectName"), r.FormValue("projectVersion"))
+	if p == nil {
+		http.Error(w, "The project could not be found.", http.StatusNotFound)
+		return
+	}
+	token := newUUID()
+	s.uploads[token] = &upload{project: p.UUID}
+	writeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
+
+// pollToken reports the upload as processing for the configured number of
+// polls, then applies it to the project.
+func (s *Server) pollToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uploads[r.PathValue("token")]
+	if !ok {
+		// Dependency-Track reports unknown tokens as not processing.
+		writeJSON(w, http.StatusOK, map[string]bool{"processing": false})
+		return
+	}
+	if u.polls < s.processingPolls {
+		u.polls++
+		writeJSON(w, http.StatusOK, map[string]bool{"processing": true})
+		return
+	}
+	if u.components != nil || u.findings != nil {
+		s.components[u.project] = u.components
+		s.findings[u.project] = u.findings
+		s.updateMetrics(u.project)
+	}
+	delete(s.uploads, r.PathValue("token"))
+	writeJSON(w, http.StatusOK, map[string]bool{"processing": false})
+}
+
+func (s *Server) updateMetrics(projectUUID string) {
+	p, ok := s.projects[projectUUID]
+	if !ok {
+		return
+	}
+	now := time.Now().UnixMilli()
+	m := &Metrics{Components: len(s.components[projectUUID]), FirstOccurrence: now, LastOccurrence: now}
+	if p.Metrics != nil {
+		m.FirstOccurrence = p.Metrics.FirstOccurrence
+		// Each calculation is strictly newer than the last, as clients wait
+		// for lastOccurrence to move.
+		m.LastOccurrence = max(now, p.Metrics.LastOccurrence+1)
+	}
+	for _, f := range s.findings[projectUUID] {
+		if f.Analysis.IsSuppressed {
+			continue
+		}
+		m.Vulnerabilities++
+		switch f.Vulnerability.Severity {
+		case "CRITICAL":
+			m.Critical++
+		case "HIGH":
+			m.High++
+		case "MEDIUM":
+			m.Medium++
+		case "LOW":
+			m.Low++
+		default:
+			m.Unassigned++
+		}
+	}
+	m.InheritedRiskScore = float64(10*m.Critical + 5*(m.High+m.Unassigned) + 3*m.Medium + m.Low)
+	p.Metrics = m
+}
+
+func (s *Server) refreshMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.projects[r.PathValue("uuid")]; !ok {
+		http.Error(w, "The project could not be found.", http.StatusNotFound)
+		return
+	}
+	s.updateMetrics(r.PathValue("uuid"))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) currentMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[r.PathValue("uuid")]
+	if !ok {
+		http.Error(w, "The project could not be found.", http.StatusNotFound)
+		return
+	}
+	if p.Metrics == nil {
+		// Dependency-Track answers with an empty body before the first calculation.
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	writeJSON(w, http.StatusOK, p.Metrics)
+}
+
+func (s *Server) listComponents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	components := slices.Clone(s.components[r.PathValue("uuid")])
+	s.mu.Unlock()
+	writePage(w, r, components)
+}
+
+func (s *Server) listFindings(w http.ResponseWriter, r *http.Request) {
+	suppressed := r.URL.Query().Get("suppressed") == "true"
+	s.mu.Lock()
+	out := []Finding{}
+	for _, f := range s.findings[r.PathValue("uuid")] {
+		if suppressed || !f.Analysis.IsSuppressed {
+			if e, ok := s.epss[f.Vulnerability.VulnID]; ok {
+				f.Vulnerability.EPSSScore, f.Vulnerability.EPSSPercentile = e[0], e[1]
+			}
+			out = append(out, f)
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) listViolations(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	out := append([]Violation{}, s.violations[r.PathValue("uuid")]...)
+	s.mu.Unlock()
+	writePage(w, r, out)
+}
+
+// writePage writes one page of items with the X-Total-Count header, using the
+// pageNumber and pageSize query parameters like Dependency-Track.
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	total := len(items)
+	size, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	page, _ := strconv.Atoi(r.URL.Query().Get("pageNumber"))
+	if size > 0 {
+		page = max(page, 1)
+		start := min((page-1)*size, total)
+		items = items[start:min(start+size, total)]
+	}
+	if items == nil {
+		items = []T{}
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, http.StatusOK, items)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func formFile(r *http.Request, name string) ([]byte, bool) {
+	if f, _, err := r.FormFile(name); err == nil {
+		defer func() { _ = f.Close() }()
+		content, err := io.ReadAll(f)
+		return content, err == nil
+	}
+	// Dependency-Track also accepts the document as a plain form field.
+	if v := r.FormValue(name); v != "" {
+		return []byte(v), true
+	}
+	return nil, false
+}
+
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,260 @@
+package fakedtrack
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testBOM = `{
+  "bomFormat": "CycloneDX",
+  "components": [
+    {"bom-ref": "a", "name": "lodash", "version": "4.17.20", "purl": "pkg:npm/lodash@4.17.20"},
+    {"bom-ref": "b", "name": "express", "version": "4.18.0", "components": [
+      {"bom-ref": "c", "name": "qs", "version": "6.5.2"}
+    ]}
+  ],
+  "vulnerabilities": [
+    {"id": "CVE-2021-23337", "ratings": [{"severity": "high"}], "affects": [{"ref": "a"}]},
+    {"id": "CVE-2022-24999", "ratings": [{"severity": "critical"}], "affects": [{"ref": "c"}]}
+  ]
+}`
+
+func do(t *testing.T, server *httptest.Server, method string, path string, body io.Reader, contentType string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Api-Key", "key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func decode[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return v
+}
+
+func uploadForm(t *testing.T, fields map[string]string, bom string) (io.Reader, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("bom", "bom.json")
+	_, _ = io.WriteString(part, bom)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+	_ = w.Close()
+	return &body, w.FormDataContentType()
+}
+
+func TestServer_UploadWorkflow(t *testing.T) {
+	fake := New(WithProcessingPolls(1))
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	if resp := do(t, server, "GET", "/api/v1/project/lookup?name=platform", nil, ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("lookup of missing parent: got %d, want 404", resp.StatusCode)
+	}
+	resp := do(t, server, "PUT", "/api/v1/project", strings.NewReader(`{"name":"platform","tags":[{"name":"team-a"}]}`), "application/json")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create parent: got %d, want 201", resp.StatusCode)
+	}
+	parent := decode[Project](t, resp)
+	if resp := do(t, server, "PUT", "/api/v1/project", strings.NewReader(`{"name":"platform"}`), "application/json"); resp.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate create: got %d, want 409", resp.StatusCode)
+	}
+
+	body, contentType := uploadForm(t, map[string]string{
+		"projectName": "svc", "projectVersion": "1.0.0", "parentName": "platform", "autoCreate": "true", "tags": "a,b", "isLatest": "true",
+	}, testBOM)
+	token := decode[map[string]string](t, do(t, server, "POST", "/api/v1/bom", body, contentType))["token"]
+
+	for i, want := range []bool{true, false} {
+		got := decode[map[string]bool](t, do(t, server, "GET", "/api/v1/bom/token/"+token, nil, ""))["processing"]
+		if got != want {
+			t.Errorf("poll %d: processing=%t, want %t", i+1, got, want)
+		}
+	}
+
+	project, ok := fake.Project("svc", "1.0.0")
+	if !ok {
+		t.Fatal("expected project to be auto-created")
+	}
+	if project.Parent == nil || project.Parent.UUID != parent.UUID || !project.IsLatest || len(project.Tags) != 2 {
+		t.Errorf("unexpected project: %+v", project)
+	}
+	if m := project.Metrics; m == nil || m.Components != 3 || m.Vulnerabilities != 2 || m.Critical != 1 || m.High != 1 {
+		t.Errorf("unexpected metrics: %+v", project.Metrics)
+	}
+
+	resp = do(t, server, "GET", "/api/v1/component/project/"+project.UUID+"?pageSize=2&pageNumber=2", nil, "")
+	if components := decode[[]Component](t, resp); len(components) != 1 || resp.Header.Get("X-Total-Count") != "3" {
+		t.Errorf("second page: got %d components, total %s", len(components), resp.Header.Get("X-Total-Count"))
+	}
+
+	fake.SetAnalysis(project.UUID, "CVE-2021-23337", Analysis{State: "FALSE_POSITIVE", IsSuppressed: true})
+	fake.SetEPSS("CVE-2022-24999", 0.42, 0.97)
+	if findings := decode[[]Finding](t, do(t, server, "GET", "/api/v1/finding/project/"+project.UUID, nil, "")); len(findings) != 1 || findings[0].Vulnerability.VulnID != "CVE-2022-24999" || findings[0].Vulnerability.EPSSScore != 0.42 {
+		t.Errorf("unsuppressed findings: %+v", findings)
+	}
+	if findings := decode[[]Finding](t, do(t, server, "GET", "/api/v1/finding/project/"+project.UUID+"?suppressed=true", nil, "")); len(findings) != 2 {
+		t.Errorf("all findings: got %d, want 2", len(findings))
+	}
+}
+
+func TestServer_RejectsInvalidBOM(t *testing.T) {
+	server := httptest.NewServer(New())
+	defer server.Close()
+
+	body, contentType := uploadForm(t, map[string]string{"projectName": "svc", "projectVersion": "1", "autoCreate": "true"}, "THIS IS NOT JSON")
+	if resp := do(t, server, "POST", "/api/v1/bom", body, contentType); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %d, want 400", resp.StatusCode)
+	}
+	body, contentType = uploadForm(t, map[string]string{"projectName": "svc", "projectVersion": "1"}, testBOM)
+	if resp := do(t, server, "POST", "/api/v1/bom", body, contentType); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("without autoCreate: got %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestServer_MetricsAndViolations(t *testing.T) {
+	fake := New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	uuid := fake.AddProject(Project{Name: "svc", Version: "1.0.0", Active: true})
+	fake.AddViolation(uuid, Violation{Type: "LICENSE", Component: Component{Name: "gpl-lib"}, PolicyCondition: PolicyCondition{Policy: Policy{Name: "No GPL", ViolationState: "FAIL"}}})
+
+	resp := do(t, server, "GET", "/api/v1/metrics/project/"+uuid+"/current", nil, "")
+	if b, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || len(b) != 0 {
+		t.Errorf("metrics before refresh: got %d %q, want empty 200", resp.StatusCode, b)
+	}
+	do(t, server, "GET", "/api/v1/metrics/project/"+uuid+"/refresh", nil, "")
+	if m := decode[Metrics](t, do(t, server, "GET", "/api/v1/metrics/project/"+uuid+"/current", nil, "")); m.LastOccurrence == 0 {
+		t.Error("expected lastOccurrence to be set after refresh")
+	}
+	violations := decode[[]Violation](t, do(t, server, "GET", "/api/v1/violation/project/"+uuid, nil, ""))
+	if len(violations) != 1 || violations[0].PolicyCondition.Policy.Name != "No GPL" {
+		t.Errorf("unexpected violations: %+v", violations)
+	}
+}
+
+func TestServer_TeamsAndACL(t *testing.T) {
+	fake := New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	project := fake.AddProject(Project{Name: "svc", Version: "1.0.0"})
+	team := fake.AddTeam("Security")
+
+	teams := decode[[]Team](t, do(t, server, "GET", "/api/v1/team", nil, ""))
+	if len(teams) != 1 || teams[0].UUID != team || teams[0].Name != "Security" {
+		t.Errorf("unexpected teams: %+v", teams)
+	}
+	mapping := `{"team":"` + team + `","project":"` + project + `"}`
+	for i, want := range []int{http.StatusOK, http.StatusConflict} {
+		if resp := do(t, server, "PUT", "/api/v1/acl/mapping", strings.NewReader(mapping), "application/json"); resp.StatusCode != want {
+			t.Errorf("mapping %d: got %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+	unknown := `{"team":"no-such-team","project":"` + project + `"}`
+	if resp := do(t, server, "PUT", "/api/v1/acl/mapping", strings.NewReader(unknown), "application/json"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown team: got %d, want 404", resp.StatusCode)
+	}
+	if acl := fake.ACL(project); len(acl) != 1 || acl[0] != team {
+		t.Errorf("unexpected ACL: %v", acl)
+	}
+}
+
+func TestServer_NotificationRules(t *testing.T) {
+	fake := New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	project := fake.AddProject(Project{Name: "svc", Version: "1.0.0"})
+	rule := fake.AddNotificationRule("Slack")
+
+	path := "/api/v1/notification/rule/" + rule + "/project/" + project
+	for i, want := range []int{http.StatusOK, http.StatusNotModified} {
+		if resp := do(t, server, "POST", path, nil, ""); resp.StatusCode != want {
+			t.Errorf("subscribe %d: got %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+	rules := decode[[]NotificationRule](t, do(t, server, "GET", "/api/v1/notification/rule", nil, ""))
+	if len(rules) != 1 || len(rules[0].Projects) != 1 || rules[0].Projects[0].UUID != project {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+	if resp := do(t, server, "POST", "/api/v1/notification/rule/no-such-rule/project/"+project, nil, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown rule: got %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestServer_APIKeyAndFailureInjection(t *testing.T) {
+	fake := New(WithAPIKey("key"))
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	fake.InjectFailure(Failure{Method: "GET", Path: "/api/v1/project", Status: http.StatusServiceUnavailable, Count: 2, RetryAfter: "1"})
+
+	for i := 0; i < 2; i++ {
+		resp := do(t, server, "GET", "/api/v1/project", nil, "")
+		if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "1" {
+			t.Errorf("request %d: got %d Retry-After=%q, want injected 503", i+1, resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+	}
+	if resp := do(t, server, "GET", "/api/v1/project", nil, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("after failures are used up: got %d, want 200", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/api/v1/project", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong credential: got %d, want 401", resp.StatusCode)
+	}
+	if got := len(fake.Requests()); got != 4 {
+		t.Errorf("Requests: got %d entries, want 4", got)
+	}
+}
+
+func TestParseFailure(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Failure
+		wantErr bool
+	}{
+		{"POST /api/v1/bom=503x2", Failure{Method: "POST", Path: "/api/v1/bom", Status: 503, Count: 2}, false},
+		{"/api/v1/project=500", Failure{Path: "/api/v1/project", Status: 500}, false},
+		{"get /api/v1/project/lookup=404", Failure{Method: "GET", Path: "/api/v1/project/lookup", Status: 404}, false},
+		{"/api/v1/bom", Failure{}, true},
+		{"/api/v1/bom=abc", Failure{}, true},
+		{"/api/v1/bom=503x0", Failure{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFailure(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFailure(%q): error %v, wantErr %t", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFailure(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package fakedtrack
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+)
+
+type NotificationRule struct {
+	UUID     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Projects []Project `json:"projects"`
+}
+
+// AddNotificationRule creates a notification rule limited to no projects and
+// returns its UUID.
+func (s *Server) AddNotificationRule(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &NotificationRule{UUID: newUUID(), Name: name, Projects: []Project{}}
+	s.rules[r.UUID] = r
+	return r.UUID
+}
+
+// NotificationRuleProjects returns the UUIDs of the projects a rule is
+// limited to.
+func (s *Server) NotificationRuleProjects(ruleUUID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	if r, ok := s.rules[ruleUUID]; ok {
+		for _, p := range r.Projects {
+			out = append(out, p.UUID)
+		}
+	}
+	return out
+}
+
+func (s *Server) listNotificationRules(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var out []NotificationRule
+	for _, rule := range s.rules {
+		out = append(out, NotificationRule{UUID: rule.UUID, Name: rule.Name, Projects: slices.Clone(rule.Projects)})
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	writePage(w, r, out)
+}
+
+// addNotificationRuleProject answers 304 Not Modified if the project is
+// already part of the rule, like Dependency-Track.
+func (s *Server) addNotificationRuleProject(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule, ok := s.rules[r.PathValue("rule")]
+	if !ok {
+		http.Error(w, "The notification rule could not be found.", http.StatusNotFound)
+		return
+	}
+	project, ok := s.projects[r.PathValue("project")]
+	if !ok {
+		http.Error(w, "The project could not be found.", http.StatusNotFound)
+		return
+	}
+	if slices.ContainsFunc(rule.Projects, func(p Project) bool { return p.UUID == project.UUID }) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	rule.Projects = append(rule.Projects, *project)
+	writeJSON(w, http.StatusOK, rule)
+}
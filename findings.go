@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// Finding is a vulnerability affecting a component of a project, as returned
+// by Dependency-Track's finding API.
+type Finding struct {
+	Component     Component            `json:"component"`
+	Vulnerability FindingVulnerability `json:"vulnerability"`
+	Analysis      FindingAnalysis      `json:"analysis"`
+}
+
+type FindingVulnerability struct {
+	UUID            string  `json:"uuid"`
+	VulnID          string  `json:"vulnId"`
+	Source          string  `json:"source"`
+	Severity        string  `json:"severity"`
+	CVSSV2BaseScore float64 `json:"cvssV2BaseScore,omitempty"`
+	CVSSV3BaseScore float64 `json:"cvssV3BaseScore,omitempty"`
+	// PatchedVersions lists the fixed versions, if the vulnerability source
+	// knows them.
+	PatchedVersions string `json:"patchedVersions,omitempty"`
+	// EPSSScore is the probability of exploitation in the next 30 days, from
+	// 0 to 1; 0 if Dependency-Track has no EPSS data for the vulnerability.
+	EPSSScore      float64              `json:"epssScore,omitempty"`
+	EPSSPercentile float64              `json:"epssPercentile,omitempty"`
+	Aliases        []VulnerabilityAlias `json:"aliases,omitempty"`
+}
+
+// VulnerabilityAlias links the identifiers different sources use for the same
+// vulnerability, e.g. a GitHub advisory and its CVE.
+type VulnerabilityAlias struct {
+	CveID  string `json:"cveId,omitempty"`
+	GhsaID string `json:"ghsaId,omitempty"`
+	OsvID  string `json:"osvId,omitempty"`
+	SnykID string `json:"snykId,omitempty"`
+}
+
+// cveIDs returns the CVE ids of the vulnerability: its own id if it is a CVE,
+// and those of its aliases.
+func (v FindingVulnerability) cveIDs() []string {
+	var ids []string
+	if strings.HasPrefix(strings.ToUpper(v.VulnID), "CVE-") {
+		ids = append(ids, strings.ToUpper(v.VulnID))
+	}
+	for _, a := range v.Aliases {
+		if id := strings.ToUpper(a.CveID); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// cvss returns the CVSS v3 base score, falling back to v2, or 0 if neither is
+// known.
+func (v FindingVulnerability) cvss() float64 {
+	if v.CVSSV3BaseScore > 0 {
+		return v.CVSSV3BaseScore
+	}
+	return v.CVSSV2BaseScore
+}
+
+type FindingAnalysis struct {
+	State        string `json:"state,omitempty"`
+	IsSuppressed bool   `json:"isSuppressed"`
+}
+
+// severities lists Dependency-Track's severity levels from most to least severe.
+var severities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "INFO", "UNASSIGNED"}
+
+// severityRank orders severities so that a more severe level ranks higher.
+// Unknown values rank with UNASSIGNED.
+func severityRank(severity string) int {
+	i := slices.Index(severities, strings.ToUpper(severity))
+	if i < 0 {
+		return 0
+	}
+	return len(severities) - 1 - i
+}
+
+// parseSeverities parses a comma-separated list of severities. An empty list
+// selects every severity.
+func parseSeverities(list string) ([]string, error) {
+	var out []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if !slices.Contains(severities, s) {
+			return nil, fmt.Errorf("invalid severity %q: must be one of %s", s, strings.Join(severities, ", "))
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func filterFindingsBySeverity(findings []Finding, include []string) []Finding {
+	if len(include) == 0 {
+		return findings
+	}
+	out := []Finding{}
+	for _, f := range findings {
+		if slices.Contains(include, strings.ToUpper(f.Vulnerability.Severity)) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// sortFindings orders findings by descending severity, then vulnerability and
+// component, so reports are stable between runs.
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if ra, rb := severityRank(a.Vulnerability.Severity), severityRank(b.Vulnerability.Severity); ra != rb {
+			return ra > rb
+		}
+		if a.Vulnerability.VulnID != b.Vulnerability.VulnID {
+			return a.Vulnerability.VulnID < b.Vulnerability.VulnID
+		}
+		return componentLabel(a.Component) < componentLabel(b.Component)
+	})
+}
+
+// sortFindingsByEPSS orders findings by descending EPSS score, falling back to
+// the order of sortFindings, so the likely-exploited issues come first.
+func sortFindingsByEPSS(findings []Finding) {
+	sortFindings(findings)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Vulnerability.EPSSScore > findings[j].Vulnerability.EPSSScore
+	})
+}
+
+// fetchFindings returns the findings for a project. Suppressed findings are
+// only included when suppressed is true.
+func fetchFindings(ctx context.Context, dependencyTrackUrl string, projectUUID string, suppressed bool, client *retryablehttp.Client) ([]Finding, error) {
+	url := fmt.Sprintf("%s/api/v1/finding/project/%s?suppressed=%t", strings.TrimRight(dependencyTrackUrl, "/"), projectUUID, suppressed)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch findings, status %d: %s", resp.StatusCode, respBody)
+	}
+
+	var findings []Finding
+	if err := json.NewDecoder(resp.Body).Decode(&findings); err != nil {
+		return nil, fmt.Errorf("failed to parse findings response: %w", err)
+	}
+	return findings, nil
+}
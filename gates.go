@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// findingGates are the checks run against a project's findings once the SBOM
+// has been imported. The zero value checks nothing.
+type findingGates struct {
+	EPSS         float64
+	EPSSSeverity string
+	KEV          kevCatalog
+}
+
+func (g findingGates) enabled() bool {
+	return g.EPSS > 0 || g.KEV != nil
+}
+
+// check prints a report of the findings failing each gate to w and returns
+// an error naming the failed gates, or nil if all passed.
+func (g findingGates) check(w io.Writer, findings []Finding) error {
+	var errs gateErrors
+	if g.EPSS > 0 {
+		if violations := epssViolations(findings, g.EPSS, g.EPSSSeverity); len(violations) > 0 {
+			writeEPSSReport(w, violations)
+			errs = append(errs, epssGateError(violations, g.EPSS, g.EPSSSeverity))
+		}
+	}
+	if g.KEV != nil {
+		if violations := kevViolations(findings, g.KEV); len(violations) > 0 {
+			writeKEVReport(w, violations)
+			errs = append(errs, kevGateError(violations))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// gateErrors reports every failed gate on one line, so it logs cleanly.
+type gateErrors []error
+
+func (e gateErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e gateErrors) Unwrap() []error { return e }
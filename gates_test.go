@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFindingGates_Check(t *testing.T) {
+	findings := []Finding{
+		epssFinding("CVE-2021-44228", "CRITICAL", 0.97),
+		epssFinding("CVE-2020-8203", "HIGH", 0.01),
+	}
+	gates := findingGates{EPSS: 0.1, KEV: kevCatalog{"CVE-2021-44228": {CVEID: "CVE-2021-44228", DueDate: "2021-12-24"}}}
+
+	var out bytes.Buffer
+	err := gates.check(&out, findings)
+	want := "1 unsuppressed finding with an EPSS score above 0.1; 1 unsuppressed finding is in the Known Exploited Vulnerabilities catalog"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got %v, want %q", err, want)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 4 || strings.Contains(out.String(), "CVE-2020-8203") {
+		t.Errorf("expected a report per failed gate listing only the offending finding, got:\n%s", out.String())
+	}
+}
+
+func TestFindingGates_Pass(t *testing.T) {
+	findings := []Finding{epssFinding("CVE-2020-8203", "HIGH", 0.01)}
+	for _, gates := range []findingGates{{}, {EPSS: 0.1, KEV: kevCatalog{}}} {
+		var out bytes.Buffer
+		if err := gates.check(&out, findings); err != nil || out.Len() != 0 {
+			t.Errorf("%+v: expected no report and no error, got %v:\n%s", gates, err, out.String())
+		}
+	}
+	if (findingGates{}).enabled() {
+		t.Error("expected the zero value to be disabled")
+	}
+}
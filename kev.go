@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// kevEntry is a vulnerability in CISA's Known Exploited Vulnerabilities
+// catalog.
+type kevEntry struct {
+	CVEID             string `json:"cveID"`
+	VendorProject     string `json:"vendorProject"`
+	Product           string `json:"product"`
+	VulnerabilityName string `json:"vulnerabilityName"`
+	DateAdded         string `json:"dateAdded"`
+	// DueDate is the YYYY-MM-DD deadline CISA sets for remediation.
+	DueDate                    string `json:"dueDate"`
+	KnownRansomwareCampaignUse string `json:"knownRansomwareCampaignUse"`
+}
+
+// kevCatalog maps upper-case CVE ids to their catalog entries.
+type kevCatalog map[string]kevEntry
+
+// loadKEVCatalog reads a catalog in the JSON format of CISA's
+// known_exploited_vulnerabilities.json feed.
+func loadKEVCatalog(path string) (kevCatalog, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read KEV catalog: %w", err)
+	}
+	var feed struct {
+		CatalogVersion  string     `json:"catalogVersion"`
+		Vulnerabilities []kevEntry `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(content, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse KEV catalog %s: %w", path, err)
+	}
+	if feed.Vulnerabilities == nil {
+		return nil, fmt.Errorf("failed to parse KEV catalog %s: no vulnerabilities list", path)
+	}
+	catalog := kevCatalog{}
+	for _, e := range feed.Vulnerabilities {
+		if e.CVEID != "" {
+			catalog[strings.ToUpper(e.CVEID)] = e
+		}
+	}
+	return catalog, nil
+}
+
+// kevViolation is a finding whose vulnerability is in the KEV catalog.
+type kevViolation struct {
+	Finding Finding
+	Entry   kevEntry
+}
+
+// kevViolations returns the unsuppressed findings whose CVE, or one of its
+// aliases, is in the catalog, earliest due date first.
+func kevViolations(findings []Finding, catalog kevCatalog) []kevViolation {
+	findings = append([]Finding(nil), findings...)
+	sortFindings(findings)
+	out := []kevViolation{}
+	for _, f := range findings {
+		if f.Analysis.IsSuppressed {
+			continue
+		}
+		for _, id := range f.Vulnerability.cveIDs() {
+			if e, ok := catalog[id]; ok {
+				out = append(out, kevViolation{Finding: f, Entry: e})
+				break
+			}
+		}
+	}
+	// Dates are YYYY-MM-DD, so they sort as strings.
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Entry.DueDate < out[j].Entry.DueDate })
+	return out
+}
+
+// kevGateError describes the findings that failed --kev-catalog.
+func kevGateError(violations []kevViolation) error {
+	what := "findings are"
+	if len(violations) == 1 {
+		what = "finding is"
+	}
+	return fmt.Errorf("%d unsuppressed %s in the Known Exploited Vulnerabilities catalog", len(violations), what)
+}
+
+func writeKEVReport(w io.Writer, violations []kevViolation) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "DUE DATE\tCVE\tSEVERITY\tVULNERABILITY\tCOMPONENT\tVERSION\tRANSOMWARE")
+	for _, v := range violations {
+		f := v.Finding
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			v.Entry.DueDate, v.Entry.CVEID, f.Vulnerability.Severity, f.Vulnerability.VulnID,
+			componentKey(f.Component), f.Component.Version, v.Entry.KnownRansomwareCampaignUse)
+	}
+	_ = tw.Flush()
+}
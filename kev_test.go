@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+const testKEVCatalog = `{
+  "title": "CISA Catalog of Known Exploited Vulnerabilities",
+  "catalogVersion": "2024.06.01",
+  "count": 2,
+  "vulnerabilities": [
+    {"cveID": "CVE-2021-44228", "vendorProject": "Apache", "product": "Log4j2", "dateAdded": "2021-12-10", "dueDate": "2021-12-24", "knownRansomwareCampaignUse": "Known"},
+    {"cveID": "CVE-2022-22965", "vendorProject": "VMware", "product": "Spring Framework", "dateAdded": "2022-04-04", "dueDate": "2022-04-25", "knownRansomwareCampaignUse": "Unknown"}
+  ]
+}`
+
+func writeKEVCatalog(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kev.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKEVCatalog(t *testing.T) {
+	catalog, err := loadKEVCatalog(writeKEVCatalog(t, testKEVCatalog))
+	if err != nil {
+		t.Fatalf("loadKEVCatalog: %v", err)
+	}
+	if e, ok := catalog["CVE-2021-44228"]; len(catalog) != 2 || !ok || e.DueDate != "2021-12-24" {
+		t.Errorf("unexpected catalog: %+v", catalog)
+	}
+
+	for _, content := range []string{"not json", `{"title": "empty"}`} {
+		if _, err := loadKEVCatalog(writeKEVCatalog(t, content)); err == nil || !strings.Contains(err.Error(), "failed to parse KEV catalog") {
+			t.Errorf("%q: expected a parse error, got %v", content, err)
+		}
+	}
+	if _, err := loadKEVCatalog(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file, got nil")
+	}
+}
+
+func TestKEVViolations(t *testing.T) {
+	catalog, err := loadKEVCatalog(writeKEVCatalog(t, testKEVCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliased := Finding{Vulnerability: FindingVulnerability{VulnID: "GHSA-36p3-wjmg-h94x", Severity: "CRITICAL",
+		Aliases: []VulnerabilityAlias{{CveID: "CVE-2022-22965", GhsaID: "GHSA-36p3-wjmg-h94x"}}}}
+	suppressed := Finding{Vulnerability: FindingVulnerability{VulnID: "CVE-2021-44228", Severity: "CRITICAL"}}
+	suppressed.Analysis.IsSuppressed = true
+	findings := []Finding{
+		aliased,
+		{Vulnerability: FindingVulnerability{VulnID: "CVE-2020-8203", Severity: "HIGH"}},
+		{Vulnerability: FindingVulnerability{VulnID: "cve-2021-44228", Severity: "LOW"}},
+		suppressed,
+	}
+
+	violations := kevViolations(findings, catalog)
+	var got []string
+	for _, v := range violations {
+		got = append(got, v.Finding.Vulnerability.VulnID+"="+v.Entry.CVEID)
+	}
+	if want := "cve-2021-44228=CVE-2021-44228,GHSA-36p3-wjmg-h94x=CVE-2022-22965"; strings.Join(got, ",") != want {
+		t.Errorf("expected listed findings by due date regardless of severity, got %v", got)
+	}
+}
+
+func TestKEVViolations_MatchesAliasesFromFake(t *testing.T) {
+	fake := fakedtrack.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	ctx := context.Background()
+	client := noRetryClient()
+	bom := []byte(`{"bomFormat":"CycloneDX","components":[{"bom-ref":"a","name":"spring-beans","version":"5.3.17"}],
+		"vulnerabilities":[{"id":"GHSA-36p3-wjmg-h94x","source":{"name":"GITHUB"},"references":[{"id":"CVE-2022-22965"}],
+		"ratings":[{"severity":"critical"}],"affects":[{"ref":"a"}]}]}`)
+
+	token, err := uploadSbom(ctx, server.URL, "svc", "", "1.0.0", bom, "", true, client)
+	if err != nil {
+		t.Fatalf("uploadSbom: %v", err)
+	}
+	if err := pollImport(ctx, server.URL, token, client, time.Millisecond, time.Second); err != nil {
+		t.Fatalf("pollImport: %v", err)
+	}
+	project, err := fetchProjectSummary(ctx, server.URL, "svc", "1.0.0", client)
+	if err != nil {
+		t.Fatalf("fetchProjectSummary: %v", err)
+	}
+	findings, err := fetchFindings(ctx, server.URL, project.UUID, false, client)
+	if err != nil {
+		t.Fatalf("fetchFindings: %v", err)
+	}
+	catalog, err := loadKEVCatalog(writeKEVCatalog(t, testKEVCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if violations := kevViolations(findings, catalog); len(violations) != 1 || violations[0].Entry.DueDate != "2022-04-25" {
+		t.Errorf("expected the GHSA finding to match its CVE alias, got %+v", violations)
+	}
+}
+
+func TestWriteKEVReport(t *testing.T) {
+	var out bytes.Buffer
+	writeKEVReport(&out, []kevViolation{{
+		Finding: Finding{
+			Component:     Component{Name: "log4j-core", Version: "2.14.1", PURL: "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+			Vulnerability: FindingVulnerability{VulnID: "CVE-2021-44228", Severity: "CRITICAL"},
+		},
+		Entry: kevEntry{CVEID: "CVE-2021-44228", DueDate: "2021-12-24", KnownRansomwareCampaignUse: "Known"},
+	}})
+
+	want := "DUE DATE    CVE             SEVERITY  VULNERABILITY   COMPONENT                                      VERSION  RANSOMWARE\n" +
+		"2021-12-24  CVE-2021-44228  CRITICAL  CVE-2021-44228  pkg:maven/org.apache.logging.log4j/log4j-core  2.14.1   Known\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// How --license-unknown treats components without an SPDX license.
+const (
+	licenseUnknownWarn = "warn"
+	licenseUnknownFail = "fail"
+)
+
+// licensePolicy decides which SPDX licenses may ship. Patterns are matched
+// case-insensitively and may use * as a wildcard, e.g. AGPL-*.
+type licensePolicy struct {
+	Deny  []string
+	Allow []string
+	// Unknown is licenseUnknownWarn or licenseUnknownFail.
+	Unknown string
+}
+
+func (p licensePolicy) enabled() bool {
+	return len(p.Deny) > 0 || len(p.Allow) > 0
+}
+
+// parseLicensePatterns parses a comma-separated list of license patterns.
+func parseLicensePatterns(list string) ([]string, error) {
+	var out []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, fmt.Errorf("invalid license pattern %q: %w", s, err)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func matchesLicense(patterns []string, term string) bool {
+	return slices.ContainsFunc(patterns, func(p string) bool {
+		ok, _ := path.Match(strings.ToUpper(p), strings.ToUpper(term))
+		return ok
+	})
+}
+
+// acceptable reports whether a single license may ship: it matches no deny
+// pattern and, if there is an allow list, one of its patterns.
+func (p licensePolicy) acceptable(term string) bool {
+	if matchesLicense(p.Deny, term) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchesLicense(p.Allow, term)
+}
+
+// licenseExpr is a parsed SPDX license expression. Leaves hold a license id,
+// including any exception as "ID WITH EXCEPTION"; inner nodes combine their
+// args with AND or OR.
+type licenseExpr struct {
+	op   string
+	term string
+	args []*licenseExpr
+}
+
+// parseLicenseExpression parses an SPDX license expression such as
+// "(MIT OR Apache-2.0) AND GPL-2.0-only WITH Classpath-exception-2.0". AND
+// binds tighter than OR.
+func parseLicenseExpression(s string) (*licenseExpr, error) {
+	p := &licenseParser{tokens: strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s))}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid license expression %q: %w", s, err)
+	}
+	if tok := p.peek(); tok != "" {
+		return nil, fmt.Errorf("invalid license expression %q: unexpected %q", s, tok)
+	}
+	return e, nil
+}
+
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *licenseParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *licenseParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *licenseParser) parseOr() (*licenseExpr, error) {
+	return p.parseBinary("OR", p.parseAnd)
+}
+
+func (p *licenseParser) parseAnd() (*licenseExpr, error) {
+	return p.parseBinary("AND", p.parsePrimary)
+}
+
+func (p *licenseParser) parseBinary(op string, operand func() (*licenseExpr, error)) (*licenseExpr, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	args := []*licenseExpr{first}
+	for strings.EqualFold(p.peek(), op) {
+		p.next()
+		arg, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 1 {
+		return first, nil
+	}
+	return &licenseExpr{op: op, args: args}, nil
+}
+
+func (p *licenseParser) parsePrimary() (*licenseExpr, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end")
+	case tok == "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return e, nil
+	case isLicenseOperator(tok):
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	if strings.EqualFold(p.peek(), "WITH") {
+		p.next()
+		exception := p.next()
+		if exception == "" || isLicenseOperator(exception) {
+			return nil, fmt.Errorf("missing exception after WITH")
+		}
+		tok += " WITH " + exception
+	}
+	return &licenseExpr{term: tok}, nil
+}
+
+func isLicenseOperator(tok string) bool {
+	return tok == "(" || tok == ")" || slices.ContainsFunc([]string{"AND", "OR", "WITH"}, func(op string) bool { return strings.EqualFold(tok, op) })
+}
+
+// unacceptable returns the licenses that keep e from satisfying the policy,
+// or nil if it does. Of an OR, one acceptable choice is enough.
+func (p licensePolicy) unacceptable(e *licenseExpr) []string {
+	if e.op == "" {
+		if p.acceptable(e.term) {
+			return nil
+		}
+		return []string{e.term}
+	}
+	var out []string
+	for _, arg := range e.args {
+		terms := p.unacceptable(arg)
+		if len(terms) == 0 && e.op == "OR" {
+			return nil
+		}
+		for _, t := range terms {
+			if !slices.Contains(out, t) {
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// licenseViolation is a component whose licenses break the policy, or can't
+// be checked because none is given as an SPDX id or expression.
+type licenseViolation struct {
+	Component Component
+	// Declared lists the component's licenses as given in the SBOM.
+	Declared []string
+	// Licenses are the declared licenses that aren't acceptable.
+	Licenses []string
+	Unknown  bool
+}
+
+// checkLicenses evaluates the licenses of every component in the BOM. A
+// component's license entries must all be acceptable; free-form license
+// names can't be matched and make the component's license unknown.
+func checkLicenses(bom *cdxBOM, policy licensePolicy) ([]licenseViolation, error) {
+	var out []licenseViolation
+	for _, c := range bom.allComponents() {
+		v := licenseViolation{Component: Component{Name: c.Name, Group: c.Group, Version: c.Version, PURL: c.PURL}}
+		var exprs []*licenseExpr
+		for _, l := range c.Licenses {
+			switch {
+			case l.Expression != "":
+				e, err := parseLicenseExpression(l.Expression)
+				if err != nil {
+					return nil, fmt.Errorf("component %s: %w", componentLabel(v.Component), err)
+				}
+				exprs = append(exprs, e)
+				v.Declared = append(v.Declared, l.Expression)
+			case l.License != nil && l.License.ID != "":
+				exprs = append(exprs, &licenseExpr{term: l.License.ID})
+				v.Declared = append(v.Declared, l.License.ID)
+			case l.License != nil && l.License.Name != "":
+				v.Unknown = true
+				v.Declared = append(v.Declared, l.License.Name)
+			}
+		}
+		if len(exprs) == 0 {
+			v.Unknown = true
+		} else {
+			v.Licenses = policy.unacceptable(&licenseExpr{op: "AND", args: exprs})
+		}
+		if v.Unknown || len(v.Licenses) > 0 {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// enforceLicensePolicy prints the components breaking the policy to w and
+// returns an error if any license is unacceptable or, with
+// --license-unknown fail, unknown.
+func enforceLicensePolicy(w io.Writer, violations []licenseViolation, policy licensePolicy) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	writeLicenseReport(w, violations)
+	denied, unknown := 0, 0
+	for _, v := range violations {
+		if len(v.Licenses) > 0 {
+			denied++
+		} else {
+			unknown++
+		}
+	}
+	if policy.Unknown == licenseUnknownFail {
+		denied, unknown = denied+unknown, 0
+	}
+	if unknown > 0 {
+		slog.Warn("Components without an SPDX license were not checked against the license policy.", "count", unknown)
+	}
+	if denied > 0 {
+		what := "components break"
+		if denied == 1 {
+			what = "component breaks"
+		}
+		return fmt.Errorf("%d %s the license policy", denied, what)
+	}
+	return nil
+}
+
+func writeLicenseReport(w io.Writer, violations []licenseViolation) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "COMPONENT\tVERSION\tLICENSES\tVIOLATION")
+	for _, v := range violations {
+		violation := "not allowed: " + strings.Join(v.Licenses, ", ")
+		if len(v.Licenses) == 0 {
+			violation = "unknown license"
+		}
+		declared := strings.Join(v.Declared, ", ")
+		if declared == "" {
+			declared = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", componentKey(v.Component), v.Component.Version, declared, violation)
+	}
+	_ = tw.Flush()
+}
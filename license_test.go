@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLicensePolicy_Expressions(t *testing.T) {
+	deny := licensePolicy{Deny: []string{"GPL-3.0-only", "agpl-*"}}
+	allow := licensePolicy{Allow: []string{"MIT", "Apache-2.0", "GPL-2.0-only WITH Classpath-exception-2.0"}}
+	tests := []struct {
+		policy     licensePolicy
+		expression string
+		want       string
+	}{
+		{deny, "MIT", ""},
+		{deny, "GPL-3.0-only", "GPL-3.0-only"},
+		{deny, "AGPL-3.0-or-later", "AGPL-3.0-or-later"},
+		{deny, "MIT OR GPL-3.0-only", ""},
+		{deny, "MIT AND GPL-3.0-only", "GPL-3.0-only"},
+		{deny, "GPL-3.0-only OR AGPL-3.0-only", "GPL-3.0-only,AGPL-3.0-only"},
+		{deny, "(MIT OR GPL-3.0-only) AND (AGPL-3.0-only OR BSD-3-Clause)", ""},
+		{deny, "MIT OR GPL-3.0-only AND AGPL-3.0-only", ""},
+		{deny, "BSD-2-Clause AND (GPL-3.0-only OR AGPL-3.0-only)", "GPL-3.0-only,AGPL-3.0-only"},
+		{allow, "Apache-2.0 and MIT", ""},
+		{allow, "Apache-2.0 AND BSD-3-Clause", "BSD-3-Clause"},
+		{allow, "GPL-2.0-only WITH Classpath-exception-2.0", ""},
+		{allow, "GPL-2.0-only", "GPL-2.0-only"},
+	}
+	for _, tt := range tests {
+		e, err := parseLicenseExpression(tt.expression)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expression, err)
+			continue
+		}
+		if got := strings.Join(tt.policy.unacceptable(e), ","); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestParseLicenseExpression_Invalid(t *testing.T) {
+	for _, expression := range []string{"", "MIT OR", "(MIT", "MIT)", "AND MIT", "GPL-2.0-only WITH", "MIT Apache-2.0"} {
+		if _, err := parseLicenseExpression(expression); err == nil {
+			t.Errorf("%q: expected an error, got nil", expression)
+		}
+	}
+}
+
+func TestParseLicensePatterns(t *testing.T) {
+	patterns, err := parseLicensePatterns("GPL-3.0-only, AGPL-*,")
+	if err != nil || strings.Join(patterns, "|") != "GPL-3.0-only|AGPL-*" {
+		t.Errorf("got %v, %v", patterns, err)
+	}
+	if _, err := parseLicensePatterns("GPL-["); err == nil {
+		t.Error("expected an error for a malformed pattern, got nil")
+	}
+}
+
+const licenseBOM = `{
+  "bomFormat": "CycloneDX",
+  "components": [
+    {"name": "lodash", "version": "4.17.21", "purl": "pkg:npm/lodash@4.17.21", "licenses": [{"license": {"id": "MIT"}}]},
+    {"name": "readline", "version": "8.2", "licenses": [{"license": {"id": "GPL-3.0-only"}}], "components": [
+      {"name": "ghostscript", "version": "10.0", "licenses": [{"expression": "AGPL-3.0-or-later OR LicenseRef-Artifex-Commercial"}]}
+    ]},
+    {"name": "dual", "version": "1.0", "licenses": [{"expression": "MIT OR GPL-3.0-only"}]},
+    {"name": "custom", "version": "2.0", "licenses": [{"license": {"name": "ACME Proprietary"}}]},
+    {"name": "bare", "version": "3.0"}
+  ]
+}`
+
+func TestCheckLicenses(t *testing.T) {
+	bom, err := parseCycloneDX([]byte(licenseBOM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations, err := checkLicenses(bom, licensePolicy{Deny: []string{"GPL-3.0-only", "AGPL-*"}})
+	if err != nil {
+		t.Fatalf("checkLicenses: %v", err)
+	}
+
+	var got []string
+	for _, v := range violations {
+		entry := v.Component.Name + "=" + strings.Join(v.Licenses, ",")
+		if v.Unknown {
+			entry += "?"
+		}
+		got = append(got, entry)
+	}
+	if want := "readline=GPL-3.0-only,custom=?,bare=?"; strings.Join(got, ",") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestCheckLicenses_InvalidExpression(t *testing.T) {
+	bom, err := parseCycloneDX([]byte(`{"bomFormat":"CycloneDX","components":[{"name":"x","version":"1","licenses":[{"expression":"MIT OR"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkLicenses(bom, licensePolicy{Deny: []string{"GPL-*"}}); err == nil || !strings.Contains(err.Error(), "x@1") {
+		t.Errorf("expected an error naming the component, got %v", err)
+	}
+}
+
+func TestEnforceLicensePolicy(t *testing.T) {
+	denied := licenseViolation{Component: Component{Name: "readline", Version: "8.2"}, Declared: []string{"GPL-3.0-only"}, Licenses: []string{"GPL-3.0-only"}}
+	unknown := licenseViolation{Component: Component{Name: "bare", Version: "3.0"}, Unknown: true}
+
+	var out bytes.Buffer
+	if err := enforceLicensePolicy(&out, []licenseViolation{unknown}, licensePolicy{Unknown: licenseUnknownWarn}); err != nil {
+		t.Errorf("expected unknown licenses to only warn, got %v", err)
+	}
+	err := enforceLicensePolicy(&out, []licenseViolation{denied, unknown}, licensePolicy{Unknown: licenseUnknownFail})
+	if err == nil || err.Error() != "2 components break the license policy" {
+		t.Errorf("expected both components to fail, got %v", err)
+	}
+
+	out.Reset()
+	writeLicenseReport(&out, []licenseViolation{denied, unknown})
+	want := "COMPONENT  VERSION  LICENSES      VIOLATION\n" +
+		"readline   8.2      GPL-3.0-only  not allowed: GPL-3.0-only\n" +
+		"bare       3.0      -             unknown license\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
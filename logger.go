@@ -1,35 +1,146 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
-	colorGray  = "\033[90m"
-	colorReset = "\033[0m"
+	colorGray   = "\033[90m"
+	colorYellow = "\033[33m"
+	colorRed    = "\033[31m"
+	colorReset  = "\033[0m"
 )
 
-// httpLogger implements retryablehttp.LeveledLogger, printing DEBUG lines in gray.
-type httpLogger struct{}
+// setupLogging installs the default slog logger, writing progress and HTTP
+// logs to w so stdout stays free for machine-readable output. Every message
+// and attribute passes through the secrets redactor.
+func setupLogging(w io.Writer, level string, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log-level %q: must be debug, info, warn or error", level)
+	}
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = &consoleHandler{w: w, level: lvl, color: useColor(w), mu: &sync.Mutex{}}
+	case "json":
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl, ReplaceAttr: redactAttr})
+	default:
+		return fmt.Errorf("invalid log-format %q: must be text or json", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
 
-func (l *httpLogger) formatKV(keysAndValues []interface{}) string {
-	var sb strings.Builder
-	for i := 0; i+1 < len(keysAndValues); i += 2 {
-		sb.WriteString(fmt.Sprintf(" %v=%v", keysAndValues[i], keysAndValues[i+1]))
+// useColor reports whether ANSI colours should be written to w: only to a
+// terminal, and never when NO_COLOR is set (https://no-color.org).
+func useColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
 	}
-	return sb.String()
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, secrets.redact(a.Value.String()))
+	case slog.KindAny:
+		return slog.String(a.Key, secrets.redact(fmt.Sprint(a.Value.Any())))
+	}
+	return a
+}
+
+// consoleHandler writes human-readable lines: INFO messages as is, other
+// levels prefixed with the level and, on a terminal, coloured.
+type consoleHandler struct {
+	w      io.Writer
+	level  slog.Level
+	color  bool
+	attrs  string
+	prefix string
+
+	mu *sync.Mutex
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
 }
 
-func (l *httpLogger) Error(msg string, keysAndValues ...interface{}) {
-	fmt.Printf("[ERROR] %s%s\n", msg, l.formatKV(keysAndValues))
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var sb strings.Builder
+	if r.Level != slog.LevelInfo {
+		sb.WriteString("[" + r.Level.String() + "] ")
+	}
+	sb.WriteString(r.Message)
+	sb.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&sb, h.prefix, a)
+		return true
+	})
+	line := secrets.redact(sb.String())
+
+	if h.color {
+		switch {
+		case r.Level >= slog.LevelError:
+			line = colorRed + line + colorReset
+		case r.Level >= slog.LevelWarn:
+			line = colorYellow + line + colorReset
+		case r.Level < slog.LevelInfo:
+			line = colorGray + line + colorReset
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line+"\n")
+	return err
 }
-func (l *httpLogger) Warn(msg string, keysAndValues ...interface{}) {
-	fmt.Printf("[WARN]  %s%s\n", msg, l.formatKV(keysAndValues))
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var sb strings.Builder
+	for _, a := range attrs {
+		writeAttr(&sb, h.prefix, a)
+	}
+	c := *h
+	c.attrs += sb.String()
+	return &c
 }
-func (l *httpLogger) Info(msg string, keysAndValues ...interface{}) {
-	fmt.Printf("[INFO]  %s%s\n", msg, l.formatKV(keysAndValues))
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix += name + "."
+	return &c
 }
-func (l *httpLogger) Debug(msg string, keysAndValues ...interface{}) {
-	fmt.Printf("%s[DEBUG] %s%s%s\n", colorGray, msg, l.formatKV(keysAndValues), colorReset)
+
+func writeAttr(sb *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeAttr(sb, prefix+a.Key+".", ga)
+		}
+		return
+	}
+	v := fmt.Sprint(a.Value.Any())
+	if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+		v = strconv.Quote(v)
+	}
+	sb.WriteString(" " + prefix + a.Key + "=" + v)
 }
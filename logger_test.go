@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLogs routes the default logger to a buffer for the rest of the test.
+func captureLogs(t *testing.T, level string, format string) *bytes.Buffer {
+	t.Helper()
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	var buf bytes.Buffer
+	if err := setupLogging(&buf, level, format); err != nil {
+		t.Fatalf("setupLogging: %v", err)
+	}
+	return &buf
+}
+
+func TestSetupLogging_TextFormat(t *testing.T) {
+	logs := captureLogs(t, "info", "text")
+	slog.Debug("hidden")
+	slog.Info("SBOM queued for import.", "token", "abc", "path", "my bom.json")
+	slog.Warn("careful")
+
+	want := "SBOM queued for import. token=abc path=\"my bom.json\"\n[WARN] careful\n"
+	if logs.String() != want {
+		t.Errorf("got %q, want %q", logs.String(), want)
+	}
+}
+
+func TestSetupLogging_DebugLevelAndAttrs(t *testing.T) {
+	logs := captureLogs(t, "DEBUG", "text")
+	slog.With("project", "svc").WithGroup("req").Debug("performing request", "method", "GET")
+
+	if got, want := logs.String(), "[DEBUG] performing request project=svc req.method=GET\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSetupLogging_JSONFormatRedacts(t *testing.T) {
+	logs := captureLogs(t, "info", "json")
+	restoreSecrets(t)
+	secrets.add("json-secret-key")
+	slog.Error("Execution failed.", "error", errors.New("rejected json-secret-key"), "url", "https://u:p@host/api")
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not JSON: %v: %s", err, logs.String())
+	}
+	if entry["level"] != "ERROR" || entry["msg"] != "Execution failed." {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if entry["error"] != "rejected [REDACTED]" || entry["url"] != "https://[REDACTED]@host/api" {
+		t.Errorf("attributes not redacted: %v", entry)
+	}
+}
+
+func TestSetupLogging_InvalidInput(t *testing.T) {
+	if err := setupLogging(&bytes.Buffer{}, "verbose", "text"); err == nil || !strings.Contains(err.Error(), "log-level") {
+		t.Errorf("expected log-level error, got %v", err)
+	}
+	if err := setupLogging(&bytes.Buffer{}, "info", "xml"); err == nil || !strings.Contains(err.Error(), "log-format") {
+		t.Errorf("expected log-format error, got %v", err)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	if useColor(&bytes.Buffer{}) {
+		t.Error("expected no colour for a non-file writer")
+	}
+	f, err := os.CreateTemp(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if useColor(f) {
+		t.Error("expected no colour for a regular file")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if useColor(os.Stderr) {
+		t.Error("expected no colour with NO_COLOR set")
+	}
+}
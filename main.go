@@ -4,17 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/rand/v2"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/hashicorp/go-retryablehttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Process exit codes. exitCancelled follows the shell convention for SIGINT.
+const (
+	exitFailure   = 1
+	exitCancelled = 130
 )
 
 type Tag struct {
@@ -24,6 +40,16 @@ type Tag struct {
 type Metrics struct {
 	Components      int `json:"components"`
 	Vulnerabilities int `json:"vulnerabilities"`
+	Critical        int `json:"critical"`
+	High            int `json:"high"`
+	Medium          int `json:"medium"`
+	Low             int `json:"low"`
+	Unassigned      int `json:"unassigned"`
+	// InheritedRiskScore weighs the vulnerabilities by severity, including
+	// those of child projects.
+	InheritedRiskScore float64 `json:"inheritedRiskScore"`
+	// LastOccurrence is when the metrics were last calculated, in Unix milliseconds.
+	LastOccurrence int64 `json:"lastOccurrence,omitempty"`
 }
 
 type Project struct {
@@ -40,12 +66,64 @@ type Project struct {
 	Metrics         *Metrics  `json:"metrics,omitempty"`
 }
 
-func newDefaultRetryClient() *retryablehttp.Client {
+type Component struct {
+	UUID    string `json:"uuid,omitempty"`
+	Name    string `json:"name"`
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+func newDefaultRetryClient(cfg *Config) (*retryablehttp.Client, error) {
 	c := retryablehttp.NewClient()
-	c.RetryMax = 20
-	c.CheckRetry = checkRetry
-	c.Logger = &httpLogger{}
-	return c
+	transport := c.HTTPClient.Transport.(*http.Transport)
+	if err := configureTransport(transport, cfg); err != nil {
+		return nil, err
+	}
+	var base http.RoundTripper = transport
+	var auth *authenticator
+	if cfg.Replay != "" {
+		replay, err := newReplayTransport(cfg.Replay)
+		if err != nil {
+			return nil, err
+		}
+		// Recordings hold no credentials; a refreshable placeholder keeps the
+		// retry behaviour on a recorded 401 the same as in the recorded run.
+		base, auth = replay, newRefreshableAuthenticator(credentialSourceFunc(func(context.Context) (credential, error) {
+			return newCredential(cfg.AuthScheme, redacted), nil
+		}))
+	} else {
+		var err error
+		if auth, err = newAuthenticator(cfg, &http.Client{Transport: transport}); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Record != "" {
+		record, err := newRecordingTransport(base, cfg.Record)
+		if err != nil {
+			return nil, err
+		}
+		base = record
+	}
+	c.HTTPClient.Transport = &authTransport{base: &tracingTransport{base: base}, auth: auth}
+	c.RetryMax = cfg.RetryMax
+	c.RetryWaitMin = cfg.RetryWaitMin
+	c.RetryWaitMax = cfg.RetryWaitMax
+	budget := newRetryBudget(cfg.RetryBudget)
+	c.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized && auth.invalidate() {
+			return true, nil
+		}
+		return budget.checkRetry(ctx, resp, err)
+	}
+	c.Backoff = budget.backoff
+	if cfg.Replay != "" {
+		// The recorded run already waited between retries.
+		c.Backoff = func(time.Duration, time.Duration, int, *http.Response) time.Duration { return 0 }
+	}
+	c.ErrorHandler = giveUp
+	c.Logger = slog.Default()
+	return c, nil
 }
 
 func main() {
@@ -53,16 +131,66 @@ func main() {
 		Use:   "sbom-uploader",
 		Short: "Uploads SBOM to Dependency-Track",
 		RunE:  runUploader,
+		// Errors are logged below, after redaction.
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadConfig(cmd.Flags())
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if err := setupLogging(os.Stderr, cfg.LogLevel, cfg.LogFormat); err != nil {
+				return err
+			}
+			ctx, err := setupTracing(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			// The span is ended in main, once the command has returned.
+			ctx, _ = tracer.Start(ctx, cmd.CommandPath())
+			cmd.SetContext(ctx)
+			return nil
+		},
 	}
 	setFlags(rootCmd.Flags())
-
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Execution failed: %v\n", err)
-		os.Exit(1)
+	setGlobalFlags(rootCmd.PersistentFlags())
+	rootCmd.AddCommand(
+		newVexCmd(),
+		newExportVexCmd(),
+		newDownloadCmd(),
+		newDiffCmd(),
+		newProjectsCmd(),
+		newFindingsCmd(),
+		newScanCmd(),
+		newServeFakeCmd(),
+	)
+
+	// Cancelling the context on SIGINT/SIGTERM aborts in-flight requests and
+	// poll waits, so a cancelled CI job exits promptly.
+	// Until the flags are parsed, log with the defaults.
+	_ = setupLogging(os.Stderr, "info", "text")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	if cmd.Context() != nil {
+		span := trace.SpanFromContext(cmd.Context())
+		endSpan(span, err)
+		span.End()
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Warn("Failed to export traces.", "error", err)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			slog.Error("Execution cancelled.")
+			os.Exit(exitCancelled)
+		}
+		slog.Error("Execution failed.", "error", err)
+		os.Exit(exitFailure)
 	}
 }
 
-func createParent(dependencyTrackUrl string, dependencyTrackKey string, parentName string, tags string, client *retryablehttp.Client) (string, error) {
+func createParent(ctx context.Context, dependencyTrackUrl string, parentName string, tags string, client *retryablehttp.Client) (string, error) {
 	url := fmt.Sprintf("%s/api/v1/project", strings.TrimRight(dependencyTrackUrl, "/"))
 	newProject := &Project{
 		Name:            parentName,
@@ -81,11 +209,10 @@ func createParent(dependencyTrackUrl string, dependencyTrackKey string, parentNa
 	}
 	reqBody := bytes.NewBuffer(jsonBody)
 
-	req, err := retryablehttp.NewRequest("PUT", url, reqBody)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "PUT", url, reqBody)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
-	req.Header.Set("X-Api-Key", dependencyTrackKey)
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
@@ -107,69 +234,72 @@ func createParent(dependencyTrackUrl string, dependencyTrackKey string, parentNa
 	return created.UUID, nil
 }
 
-func ensureParentExists(dependencyTrackUrl string, dependencyTrackKey string, parentName string, tags string, client *retryablehttp.Client) error {
-	fmt.Printf("Ensuring parent project %q exists...\n", parentName)
-	url := fmt.Sprintf("%s/api/v1/project/lookup?name=%s", strings.TrimRight(dependencyTrackUrl, "/"), parentName)
-	req, err := retryablehttp.NewRequest("GET", url, nil)
+// ensureParentExists looks up the parent project, creating it if it doesn't
+// exist, and returns its UUID and whether it was created.
+func ensureParentExists(ctx context.Context, dependencyTrackUrl string, parentName string, tags string, client *retryablehttp.Client) (string, bool, error) {
+	slog.Info("Ensuring parent project exists...", "parent", parentName)
+	query := url.Values{"name": {parentName}}
+	lookupURL := fmt.Sprintf("%s/api/v1/project/lookup?%s", strings.TrimRight(dependencyTrackUrl, "/"), query.Encode())
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", lookupURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", false, fmt.Errorf("failed to create request: %w", err)
 	}
-	req.Header.Set("X-Api-Key", dependencyTrackKey)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("HTTP request failed: %w", err)
+		return "", false, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	if resp.StatusCode == http.StatusNotFound {
-		fmt.Printf("Parent project %q not found, creating it...\n", parentName)
-		uuid, err := createParent(dependencyTrackUrl, dependencyTrackKey, parentName, tags, client)
+		slog.Info("Parent project not found, creating it...", "parent", parentName)
+		uuid, err := createParent(ctx, dependencyTrackUrl, parentName, tags, client)
 		if err != nil {
-			return err
+			return "", false, err
 		}
-		fmt.Printf("Parent project %q created (uuid: %s).\n", parentName, uuid)
-		return nil
+		slog.Info("Parent project created.", "parent", parentName, "uuid", uuid)
+		return uuid, true, nil
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("parent project lookup failed with status %d: %s", resp.StatusCode, respBody)
+		return "", false, fmt.Errorf("parent project lookup failed with status %d: %s", resp.StatusCode, respBody)
 	}
 
 	var project Project
 	if err := json.NewDecoder(resp.Body).Decode(&project); err != nil {
-		return fmt.Errorf("unable to decode response: %w", err)
+		return "", false, fmt.Errorf("unable to decode response: %w", err)
 	}
-	fmt.Printf("Parent project %q found (uuid: %s).\n", parentName, project.UUID)
-	return nil
+	slog.Info("Parent project found.", "parent", parentName, "uuid", project.UUID)
+	return project.UUID, false, nil
 }
 
-func uploadSbom(dependencyTrackUrl string, dependencyTrackKey string, projectName string, parentName string, projectVersion string, sbomFilePath string, tags string, latest bool, client *retryablehttp.Client) (string, error) {
-	// Read SBOM from file or stdin
-	var sbomContent []byte
-	var err error
+// readSbom reads the SBOM from sbomFilePath, or from stdin when it is empty.
+func readSbom(sbomFilePath string) ([]byte, error) {
 	if sbomFilePath != "" {
-		fmt.Printf("Reading SBOM from file: %s\n", sbomFilePath)
-		sbomContent, err = os.ReadFile(sbomFilePath)
+		slog.Info("Reading SBOM from file...", "path", sbomFilePath)
+		sbomContent, err := os.ReadFile(sbomFilePath)
 		if err != nil {
-			return "", fmt.Errorf("failed to read SBOM file: %w", err)
+			return nil, fmt.Errorf("failed to read SBOM file: %w", err)
 		}
-		fmt.Printf("SBOM file read (%d bytes).\n", len(sbomContent))
-	} else {
-		fmt.Println("Reading SBOM from stdin...")
-		sbomContent, err = io.ReadAll(os.Stdin)
-		if err != nil {
-			return "", fmt.Errorf("failed to read SBOM from stdin: %w", err)
-		}
-		if len(sbomContent) == 0 {
-			return "", fmt.Errorf("no SBOM content provided (empty stdin)")
-		}
-		fmt.Printf("SBOM read from stdin (%d bytes).\n", len(sbomContent))
+		slog.Info("SBOM file read.", "bytes", len(sbomContent))
+		return sbomContent, nil
+	}
+	slog.Info("Reading SBOM from stdin...")
+	sbomContent, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM from stdin: %w", err)
+	}
+	if len(sbomContent) == 0 {
+		return nil, fmt.Errorf("no SBOM content provided (empty stdin)")
 	}
+	slog.Info("SBOM read from stdin.", "bytes", len(sbomContent))
+	return sbomContent, nil
+}
 
+func uploadSbom(ctx context.Context, dependencyTrackUrl string, projectName string, parentName string, projectVersion string, sbomContent []byte, tags string, latest bool, client *retryablehttp.Client) (string, error) {
 	// Prepare multipart/form-data
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
@@ -199,15 +329,14 @@ func uploadSbom(dependencyTrackUrl string, dependencyTrackKey string, projectNam
 		return "", fmt.Errorf("failed to finalize multipart body: %w", err)
 	}
 
-	fmt.Printf("Uploading SBOM for project %q version %q (parent: %q)...\n", projectName, projectVersion, parentName)
+	slog.Info("Uploading SBOM...", "project", projectName, "version", projectVersion, "parent", parentName)
 	// Create HTTP request
 	url := fmt.Sprintf("%s/api/v1/bom", strings.TrimRight(dependencyTrackUrl, "/"))
-	req, err := retryablehttp.NewRequest("POST", url, &requestBody)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "POST", url, &requestBody)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("X-Api-Key", dependencyTrackKey)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -228,58 +357,122 @@ func uploadSbom(dependencyTrackUrl string, dependencyTrackKey string, projectNam
 	if err := json.NewDecoder(resp.Body).Decode(&uploadResp); err != nil {
 		return "", fmt.Errorf("failed to parse upload response: %w", err)
 	}
-	fmt.Printf("SBOM queued for import (token: %s).\n", uploadResp.Token)
+	slog.Info("SBOM queued for import.", "token", uploadResp.Token)
 	return uploadResp.Token, nil
 }
 
-func pollImport(dependencyTrackUrl string, dependencyTrackKey string, token string, client *retryablehttp.Client, interval time.Duration) error {
-	const timeout = 5 * time.Minute
+// maxPollInterval caps the exponential backoff between poll requests.
+const maxPollInterval = 30 * time.Second
+
+// pollImport waits until Dependency-Track has finished processing the upload
+// identified by token.
+func pollImport(ctx context.Context, dependencyTrackUrl string, token string, client *retryablehttp.Client, interval time.Duration, timeout time.Duration) error {
 	url := fmt.Sprintf("%s/api/v1/bom/token/%s", strings.TrimRight(dependencyTrackUrl, "/"), token)
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		req, err := retryablehttp.NewRequest("GET", url, nil)
+	return pollUntil(ctx, "import to complete", interval, timeout, func(ctx context.Context) (bool, error) {
+		req, err := retryablehttp.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
-			return fmt.Errorf("failed to create poll request: %w", err)
+			return false, fmt.Errorf("failed to create poll request: %w", err)
 		}
-		req.Header.Set("X-Api-Key", dependencyTrackKey)
 
 		resp, err := client.Do(req)
 		if err != nil {
-			return fmt.Errorf("poll request failed: %w", err)
+			return false, fmt.Errorf("poll request failed: %w", err)
 		}
+		defer func(Body io.ReadCloser) {
+			_ = Body.Close()
+		}(resp.Body)
 
 		if resp.StatusCode != http.StatusOK {
 			respBody, _ := io.ReadAll(resp.Body)
-			_ = resp.Body.Close()
-			return fmt.Errorf("poll request failed with status %d: %s", resp.StatusCode, respBody)
+			return false, fmt.Errorf("poll request failed with status %d: %s", resp.StatusCode, respBody)
 		}
 
 		var tokenResp struct {
 			Processing bool `json:"processing"`
 		}
-		err = json.NewDecoder(resp.Body).Decode(&tokenResp)
-		_ = resp.Body.Close()
-		if err != nil {
-			return fmt.Errorf("failed to parse poll response: %w", err)
+		if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+			return false, fmt.Errorf("failed to parse poll response: %w", err)
 		}
 
-		if !tokenResp.Processing {
+		if tokenResp.Processing {
+			slog.Info("Still processing, waiting...")
+		}
+		return !tokenResp.Processing, nil
+	})
+}
+
+// pollUntil calls check until it reports done or returns an error. The wait
+// between calls starts at interval and doubles (with jitter) up to
+// maxPollInterval. A timeout of zero waits until ctx is done; what describes
+// the awaited condition in the timeout error.
+func pollUntil(ctx context.Context, what string, interval time.Duration, timeout time.Duration, check func(ctx context.Context) (bool, error)) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	timedOut := func() error {
+		return &unavailableError{fmt.Errorf("timed out waiting for %s after %s", what, timeout)}
+	}
+
+	for attempt, wait := 1, interval; ; attempt, wait = attempt+1, nextPollInterval(wait) {
+		var done bool
+		err := traced(ctx, "poll "+what, func(ctx context.Context) error {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Int("poll.attempt", attempt))
+			var err error
+			done, err = check(ctx)
+			return err
+		})
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return timedOut()
+			}
+			return err
+		}
+		if done {
 			return nil
 		}
-		fmt.Println("Still processing, waiting...")
-		time.Sleep(interval)
+		if err := sleepContext(ctx, jitter(wait)); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return timedOut()
+			}
+			return err
+		}
+	}
+}
+
+func nextPollInterval(d time.Duration) time.Duration {
+	return min(d*2, max(maxPollInterval, d))
+}
+
+// jitter returns a random duration in [d/2, d] so that many pipelines polling
+// the same server don't do so in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
 	}
-	return fmt.Errorf("timed out waiting for import to complete after %s", timeout)
+	return d/2 + rand.N(d/2+1)
 }
 
-func fetchProjectSummary(dependencyTrackUrl string, dependencyTrackKey string, projectName string, projectVersion string, client *retryablehttp.Client) (*Project, error) {
-	url := fmt.Sprintf("%s/api/v1/project/lookup?name=%s&version=%s",
-		strings.TrimRight(dependencyTrackUrl, "/"), projectName, projectVersion)
-	req, err := retryablehttp.NewRequest("GET", url, nil)
+// sleepContext sleeps for d, returning early with ctx's error if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func fetchProjectSummary(ctx context.Context, dependencyTrackUrl string, projectName string, projectVersion string, client *retryablehttp.Client) (*Project, error) {
+	query := url.Values{"name": {projectName}, "version": {projectVersion}}
+	lookupURL := fmt.Sprintf("%s/api/v1/project/lookup?%s", strings.TrimRight(dependencyTrackUrl, "/"), query.Encode())
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", lookupURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	req.Header.Set("X-Api-Key", dependencyTrackKey)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -301,7 +494,24 @@ func fetchProjectSummary(dependencyTrackUrl string, dependencyTrackKey string, p
 	return &project, nil
 }
 
+// resolveProjectUUID returns uuid if set, otherwise looks up the project
+// identified by the configured name and version.
+func resolveProjectUUID(ctx context.Context, cfg *Config, uuid string, client *retryablehttp.Client) (string, error) {
+	if uuid != "" {
+		return uuid, nil
+	}
+	if cfg.Name == "" || cfg.Version == "" {
+		return "", fmt.Errorf("missing required input: either --uuid or both --name and --version")
+	}
+	project, err := fetchProjectSummary(ctx, cfg.URL, cfg.Name, cfg.Version, client)
+	if err != nil {
+		return "", err
+	}
+	return project.UUID, nil
+}
+
 func runUploader(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
 	cfg, err := loadConfig(cmd.Flags())
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
@@ -310,41 +520,402 @@ func runUploader(cmd *cobra.Command, _ []string) error {
 	if err := cfg.validate(); err != nil {
 		return err
 	}
+	// Past validation, errors are about the run rather than the invocation, so
+	// they shouldn't bury the output in usage text.
+	cmd.SilenceUsage = true
 
-	client := newDefaultRetryClient()
+	trace.SpanFromContext(ctx).SetAttributes(projectAttributes(cfg)...)
 
-	if err := ensureParentExists(cfg.URL, cfg.APIKey, cfg.Parent, cfg.Tags, client); err != nil {
+	var sbomContent []byte
+	if err := traced(ctx, "read SBOM", func(context.Context) error {
+		if sbomContent, err = readSbom(cfg.SBOM); err != nil {
+			return err
+		}
+		if cfg.DryRun {
+			return validateSbom(sbomContent)
+		}
+		return nil
+	}); err != nil {
 		return err
 	}
-	token, err := uploadSbom(cfg.URL, cfg.APIKey, cfg.Name, cfg.Parent, cfg.Version, cfg.SBOM, cfg.Tags, cfg.Latest, client)
+
+	policy, err := cfg.licensePolicy()
 	if err != nil {
 		return err
 	}
+	if policy.enabled() {
+		if err := traced(ctx, "check licenses", func(context.Context) error {
+			bom, err := parseCycloneDX(bytes.TrimSpace(sbomContent))
+			if err != nil {
+				return fmt.Errorf("the license policy needs a CycloneDX JSON SBOM: %w", err)
+			}
+			violations, err := checkLicenses(bom, policy)
+			if err != nil {
+				return err
+			}
+			return enforceLicensePolicy(cmd.OutOrStdout(), violations, policy)
+		}); err != nil {
+			return err
+		}
+	}
 
-	fmt.Println("✅ SBOM upload successful.")
-	if cfg.Poll {
-		fmt.Println("⏳ Polling until fully imported...")
-		if err := pollImport(cfg.URL, cfg.APIKey, token, client, 2*time.Second); err != nil {
+	// The catalog is read up front, so a missing or corrupt file fails the run
+	// before anything is uploaded.
+	gates := findingGates{EPSS: cfg.FailOnEPSS, EPSSSeverity: cfg.FailOnEPSSSeverity}
+	if cfg.KEVCatalog != "" {
+		if gates.KEV, err = loadKEVCatalog(cfg.KEVCatalog); err != nil {
 			return err
 		}
-		project, err := fetchProjectSummary(cfg.URL, cfg.APIKey, cfg.Name, cfg.Version, client)
+	}
+
+	var client *retryablehttp.Client
+	if cfg.DryRun {
+		client = newDryRunClient(cfg, cmd.OutOrStdout())
+	} else if client, err = newDefaultRetryClient(cfg); err != nil {
+		return err
+	}
+	afterUpload, err := uploadAndReport(ctx, cmd, cfg, client, sbomContent, gates)
+	// Only an unavailable server is scanned around; a rejected SBOM, missing
+	// permissions or a failure once the SBOM is uploaded must still fail the
+	// run.
+	var unavailable *unavailableError
+	if err == nil || cfg.OSVFallback == "" || cfg.DryRun || afterUpload || ctx.Err() != nil || !errors.As(err, &unavailable) {
+		return err
+	}
+	if gates.EPSS > 0 {
+		slog.Warn("Not scanning the SBOM offline, as OSV records have no EPSS scores to check --fail-on-epss against.", "osv-db", cfg.OSVFallback)
+		return err
+	}
+	slog.Warn("Dependency-Track is unavailable, scanning the SBOM offline instead.", "error", err, "osv-db", cfg.OSVFallback)
+	return traced(ctx, "offline scan", func(context.Context) error {
+		return scanOffline(cmd.OutOrStdout(), sbomContent, cfg.OSVFallback, cfg.TopComponents, gates)
+	})
+}
+
+// uploadAndReport uploads the SBOM and, depending on cfg, waits for the
+// import, prints the most vulnerable components and checks the gates.
+// The returned bool reports whether the error came after the upload, other
+// than while waiting for the import.
+func uploadAndReport(ctx context.Context, cmd *cobra.Command, cfg *Config, client *retryablehttp.Client, sbomContent []byte, gates findingGates) (bool, error) {
+	var err error
+	retries := 0
+	client.RequestLogHook = func(_ retryablehttp.Logger, _ *http.Request, attempt int) {
+		if attempt > 0 {
+			retries++
+		}
+	}
+
+	// Teams and notification rules are resolved first, so a key lacking the
+	// permissions they need fails before anything is created.
+	var teams []Team
+	if len(cfg.Teams) > 0 {
+		if teams, err = resolveTeams(ctx, cfg.URL, cfg.Teams, client); err != nil {
+			return false, err
+		}
+	}
+	var rules []NotificationRule
+	if len(cfg.NotificationRules) > 0 {
+		if rules, err = resolveNotificationRules(ctx, cfg.URL, cfg.NotificationRules, client); err != nil {
+			return false, err
+		}
+	}
+
+	if err := traced(ctx, "ensure parent", func(ctx context.Context) error {
+		parentUUID, created, err := ensureParentExists(ctx, cfg.URL, cfg.Parent, cfg.Tags, client)
 		if err != nil {
 			return err
 		}
-		components, vulnerabilities := 0, 0
+		if created && len(teams) > 0 {
+			slog.Info("Granting teams access to the parent project...", "parent", cfg.Parent)
+			if err := grantTeamAccess(ctx, cfg.URL, parentUUID, teams, client); err != nil {
+				return err
+			}
+		}
+		return subscribeToNotificationRules(ctx, cfg.URL, parentUUID, rules, client)
+	}); err != nil {
+		return false, err
+	}
+	// The upload auto-creates the project, so whether it is new can only be
+	// told beforehand.
+	childExisted := true
+	if len(teams) > 0 {
+		if childExisted, err = projectExists(ctx, cfg.URL, cfg.Name, cfg.Version, client); err != nil {
+			return false, err
+		}
+	}
+	uploadedAt := time.Now()
+	var token string
+	if err := traced(ctx, "upload SBOM", func(ctx context.Context) error {
+		token, err = uploadSbom(ctx, cfg.URL, cfg.Name, cfg.Parent, cfg.Version, sbomContent, cfg.Tags, cfg.Latest, client)
+		return err
+	}); err != nil {
+		return false, err
+	}
+	uploadDuration := time.Since(uploadedAt)
+	if !childExisted || len(rules) > 0 {
+		project, err := fetchProjectSummary(ctx, cfg.URL, cfg.Name, cfg.Version, client)
+		if err != nil {
+			return true, err
+		}
+		if !childExisted {
+			slog.Info("Granting teams access to the new project...", "project", cfg.Name, "version", cfg.Version)
+			if err := grantTeamAccess(ctx, cfg.URL, project.UUID, teams, client); err != nil {
+				return true, err
+			}
+		}
+		if err := subscribeToNotificationRules(ctx, cfg.URL, project.UUID, rules, client); err != nil {
+			return true, err
+		}
+	}
+	if cfg.DryRun {
+		if cfg.VEX != "" {
+			// A real run waits for the import before this request.
+			if _, err := uploadVex(ctx, cfg.URL, cfg.Name, cfg.Version, cfg.VEX, client); err != nil {
+				return false, err
+			}
+		}
+		slog.Info("Dry run complete, nothing was sent.")
+		return false, nil
+	}
+
+	slog.Info("SBOM upload successful.")
+	// VEX statements only apply to components that have already been imported,
+	// so a VEX upload always waits for the BOM regardless of --poll.
+	poll := cfg.Poll || cfg.WaitForMetrics || gates.enabled()
+	var importWait time.Duration
+	if poll || cfg.VEX != "" {
+		slog.Info("Polling until fully imported...")
+		pollStart := time.Now()
+		if err := traced(ctx, "wait for import", func(ctx context.Context) error {
+			return pollImport(ctx, cfg.URL, token, client, cfg.pollWait(), cfg.PollTimeout)
+		}); err != nil {
+			return false, err
+		}
+		importWait = time.Since(pollStart)
+	}
+	if cfg.VEX != "" {
+		if err := traced(ctx, "upload VEX", func(ctx context.Context) error {
+			vexToken, err := uploadVex(ctx, cfg.URL, cfg.Name, cfg.Version, cfg.VEX, client)
+			if err != nil {
+				return err
+			}
+			slog.Info("Polling until VEX is processed...")
+			return pollImport(ctx, cfg.URL, vexToken, client, cfg.pollWait(), cfg.PollTimeout)
+		}); err != nil {
+			return true, err
+		}
+		slog.Info("VEX processed successfully.")
+	}
+	if cfg.WaitForMetrics {
+		if err := traced(ctx, "wait for metrics", func(ctx context.Context) error {
+			project, err := fetchProjectSummary(ctx, cfg.URL, cfg.Name, cfg.Version, client)
+			if err != nil {
+				return err
+			}
+			slog.Info("Waiting for project metrics to be refreshed...")
+			return refreshMetrics(ctx, cfg.URL, project.UUID, client, cfg.pollWait(), cfg.PollTimeout)
+		}); err != nil {
+			return true, err
+		}
+	}
+	var imported *Metrics
+	var gateErr error
+	if poll {
+		var project *Project
+		if err := traced(ctx, "fetch summary", func(ctx context.Context) error {
+			project, err = fetchProjectSummary(ctx, cfg.URL, cfg.Name, cfg.Version, client)
+			return err
+		}); err != nil {
+			return true, err
+		}
+		components, vulnerabilities, riskScore := 0, 0, 0.0
 		if project.Metrics != nil {
 			components = project.Metrics.Components
 			vulnerabilities = project.Metrics.Vulnerabilities
+			riskScore = project.Metrics.InheritedRiskScore
+		}
+		slog.Info("SBOM imported successfully.", "components", components, "vulnerabilities", vulnerabilities, "risk_score", riskScore)
+		imported = project.Metrics
+
+		// Metrics may not be recalculated yet, so findings are fetched even if
+		// they report no vulnerabilities.
+		if cfg.TopComponents > 0 || gates.enabled() {
+			var findings []Finding
+			if err := traced(ctx, "fetch findings", func(ctx context.Context) error {
+				findings, err = fetchFindings(ctx, cfg.URL, project.UUID, false, client)
+				return err
+			}); err != nil {
+				return true, err
+			}
+			out := cmd.OutOrStdout()
+			if risks := topVulnerableComponents(findings, cfg.TopComponents); len(risks) > 0 {
+				writeTopComponents(out, risks, terminalWidth(out), useColor(out))
+			}
+			gateErr = gates.check(out, findings)
+		}
+	}
+
+	if cfg.MetricsFile != "" {
+		sbomComponents := -1
+		if bom, err := parseCycloneDX(sbomContent); err == nil {
+			sbomComponents = len(bom.allComponents())
+		}
+		err := writeMetricsFile(cfg.MetricsFile, uploadMetrics{
+			Project:        cfg.Name,
+			Version:        cfg.Version,
+			Parent:         cfg.Parent,
+			UploadedAt:     uploadedAt,
+			UploadDuration: uploadDuration,
+			ImportWait:     importWait,
+			Retries:        retries,
+			SBOMSize:       len(sbomContent),
+			SBOMComponents: sbomComponents,
+			Imported:       imported,
+		})
+		if err != nil {
+			return true, err
 		}
-		fmt.Printf("✅ SBOM imported successfully (%d components, %d vulnerabilities).\n", components, vulnerabilities)
 	}
 
-	return nil
+	// The metrics file is written even if a gate fails, so failed builds
+	// still show up in the dashboards.
+	return true, gateErr
 }
 
+// checkRetry retries connection errors and 5xx responses like retryablehttp's
+// default policy. Client errors are final, except 408 Request Timeout and 429
+// Too Many Requests, which signal a transient condition on the server.
 func checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	var authErr *authError
+	if errors.As(err, &authErr) {
+		return false, err
+	}
+	if resp != nil && (resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return true, nil
+	}
 	if resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 {
 		return false, nil
 	}
 	return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
 }
+
+// unavailableError means Dependency-Track couldn't be reached, kept failing on
+// its side or didn't finish in time; only these errors trigger --osv-fallback.
+type unavailableError struct{ err error }
+
+func (e *unavailableError) Error() string { return e.err.Error() }
+func (e *unavailableError) Unwrap() error { return e.err }
+
+// giveUp is the retry client's ErrorHandler. Like retryablehttp's default it
+// drains the response and reports the attempts, and it marks connection errors
+// and 5xx responses that outlasted the retries as unavailableError.
+func giveUp(resp *http.Response, err error, attempts int) (*http.Response, error) {
+	var authErr *authError
+	if resp == nil {
+		if err == nil || errors.As(err, &authErr) {
+			return nil, err
+		}
+		return nil, &unavailableError{fmt.Errorf("giving up after %d attempt(s): %w", attempts, err)}
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+	giveUpErr := fmt.Errorf("giving up after %d attempt(s) with status %d", attempts, resp.StatusCode)
+	if resp.Request != nil {
+		giveUpErr = fmt.Errorf("%s %s %w", resp.Request.Method, resp.Request.URL.Redacted(), giveUpErr)
+	}
+	if err != nil {
+		giveUpErr = fmt.Errorf("%w: %w", giveUpErr, err)
+	}
+	if resp.StatusCode >= 500 {
+		return nil, &unavailableError{giveUpErr}
+	}
+	return nil, giveUpErr
+}
+
+// maxRetryAfter caps the wait a Retry-After header can ask for, so a server
+// can't stall the run indefinitely even with an unlimited retry budget.
+const maxRetryAfter = 5 * time.Minute
+
+// retryBackoff waits as long as the server asks via Retry-After, falling back
+// to retryablehttp's exponential backoff between waitMin and waitMax.
+func retryBackoff(waitMin, waitMax time.Duration, attempt int, resp *http.Response) time.Duration {
+	// A 401 is only retried once the credential has been refreshed, so
+	// there's nothing to wait for.
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		return 0
+	}
+	if wait, ok := retryAfter(resp); ok {
+		return wait
+	}
+	return retryablehttp.DefaultBackoff(waitMin, waitMax, attempt, resp)
+}
+
+// retryAfter returns the response's Retry-After wait, capped at maxRetryAfter.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return min(wait, maxRetryAfter), ok
+}
+
+// parseRetryAfter parses a Retry-After header given either as delay-seconds or
+// as an HTTP date.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// retryBudget bounds the total time spent waiting between retries over the
+// whole run, so a struggling server can't stretch a pipeline step
+// indefinitely. A zero budget is unlimited.
+type retryBudget struct {
+	mu        sync.Mutex
+	total     time.Duration
+	remaining time.Duration
+}
+
+func newRetryBudget(total time.Duration) *retryBudget {
+	return &retryBudget{total: total, remaining: total}
+}
+
+func (b *retryBudget) checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	retry, checkErr := checkRetry(ctx, resp, err)
+	if !retry || checkErr != nil || b.total == 0 {
+		return retry, checkErr
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false, fmt.Errorf("retry budget of %s exhausted", b.total)
+	}
+	// Retrying before the server's Retry-After would go against its
+	// instruction, so give up instead.
+	if wait, ok := retryAfter(resp); ok && wait > b.remaining {
+		return false, fmt.Errorf("server asked to retry after %s, more than the remaining retry budget of %s", wait, b.remaining)
+	}
+	return true, nil
+}
+
+func (b *retryBudget) backoff(waitMin, waitMax time.Duration, attempt int, resp *http.Response) time.Duration {
+	wait := retryBackoff(waitMin, waitMax, attempt, resp)
+	if b.total == 0 {
+		return wait
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wait = min(wait, b.remaining)
+	b.remaining -= wait
+	return wait
+}
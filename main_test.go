@@ -1,24 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/spf13/cobra"
 )
 
-// noRetryClient returns a client with retries disabled, suitable for unit tests.
+// noRetryClient returns a client with retries disabled that authenticates with
+// the API key "test-key", suitable for unit tests.
 func noRetryClient() *retryablehttp.Client {
+	return noRetryClientWithKey("test-key")
+}
+
+func noRetryClientWithKey(key string) *retryablehttp.Client {
 	c := retryablehttp.NewClient()
 	c.RetryMax = 0
+	c.HTTPClient.Transport = &authTransport{
+		base: c.HTTPClient.Transport,
+		auth: newStaticAuthenticator(newCredential(authSchemeAPIKey, key)),
+	}
 	return c
 }
 
@@ -36,6 +50,22 @@ func writeTempSbom(t *testing.T, content []byte) string {
 	return tmp.Name()
 }
 
+// runUpload runs the upload command with args, isolated from any config file,
+// and returns what it printed to stdout.
+func runUpload(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	isolateConfigDiscovery(t)
+	cmd := &cobra.Command{Use: "sbom-uploader", RunE: runUploader, SilenceErrors: true, SilenceUsage: true}
+	setFlags(cmd.Flags())
+	setGlobalFlags(cmd.PersistentFlags())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs(args)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
 // --- checkRetry ---
 
 func TestCheckRetry_4xxDoesNotRetry(t *testing.T) {
@@ -48,6 +78,169 @@ func TestCheckRetry_4xxDoesNotRetry(t *testing.T) {
 	}
 }
 
+func TestCheckRetry_RetriesRequestTimeoutAndTooManyRequests(t *testing.T) {
+	for _, status := range []int{http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		resp := &http.Response{StatusCode: status}
+		retry, err := checkRetry(context.Background(), resp, nil)
+		if !retry || err != nil {
+			t.Errorf("status %d: expected (true, nil), got (%v, %v)", status, retry, err)
+		}
+	}
+}
+
+// --- retry policy ---
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.header, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q): got (%s, %v), want (%s, %v)", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// mustRetryClient builds the production client for cfg, failing the test on error.
+func mustRetryClient(t *testing.T, cfg *Config) *retryablehttp.Client {
+	t.Helper()
+	c, err := newDefaultRetryClient(cfg)
+	if err != nil {
+		t.Fatalf("failed to create retry client: %v", err)
+	}
+	return c
+}
+
+func retryConfig() *Config {
+	return &Config{APIKey: "test-key", RetryMax: 3, RetryWaitMin: time.Hour, RetryWaitMax: time.Hour}
+}
+
+func TestRetryClient_HonoursRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// Without Retry-After the hour-long minimum wait would stall the test.
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := mustRetryClient(t, retryConfig()).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status: got %d, want 200", resp.StatusCode)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", calls.Load())
+	}
+}
+
+func TestRetryClient_StopsWhenBudgetExhausted(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := retryConfig()
+	cfg.RetryBudget = time.Nanosecond
+	_, err := mustRetryClient(t, cfg).Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "retry budget") {
+		t.Errorf("expected retry budget error, got: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 requests (one retry within budget), got %d", calls.Load())
+	}
+}
+
+func TestRetryClient_StopsWhenRetryAfterExceedsBudget(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	cfg := retryConfig()
+	cfg.RetryBudget = time.Minute
+	_, err := mustRetryClient(t, cfg).Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "server asked to retry after 2m0s") {
+		t.Errorf("expected a Retry-After error, got: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected no retry, got %d requests", calls.Load())
+	}
+}
+
+func TestRetryBackoff_CapsRetryAfter(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"86400"}}}
+	if got := retryBackoff(time.Second, time.Second, 1, resp); got != maxRetryAfter {
+		t.Errorf("got %s, want %s", got, maxRetryAfter)
+	}
+}
+
+func TestRetryClient_RetryMax(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusRequestTimeout)
+	}))
+	defer server.Close()
+
+	cfg := retryConfig()
+	cfg.RetryWaitMin, cfg.RetryWaitMax = 0, 0
+	if _, err := mustRetryClient(t, cfg).Get(server.URL); err == nil {
+		t.Error("expected error after exhausting retries, got nil")
+	}
+	if calls.Load() != 4 {
+		t.Errorf("expected 4 requests (1 + 3 retries), got %d", calls.Load())
+	}
+}
+
+func TestRetryClient_MarksUnavailableServer(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	cfg := retryConfig()
+	cfg.RetryMax = 0
+	client := mustRetryClient(t, cfg)
+	var unavailable *unavailableError
+
+	if _, err := client.Get(closed.URL); !errors.As(err, &unavailable) {
+		t.Errorf("connection error: expected unavailableError, got %v", err)
+	}
+	for status, want := range map[int]bool{http.StatusServiceUnavailable: true, http.StatusTooManyRequests: false} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		_, err := client.Get(server.URL)
+		server.Close()
+		if err == nil || !strings.Contains(err.Error(), strconv.Itoa(status)) {
+			t.Errorf("status %d: expected an error naming the status, got %v", status, err)
+		}
+		if errors.As(err, &unavailable) != want {
+			t.Errorf("status %d: unavailable = %v, want %v", status, !want, want)
+		}
+	}
+}
+
 // --- uploadSbom ---
 
 func TestUploadSbom_Returns200(t *testing.T) {
@@ -63,9 +256,7 @@ func TestUploadSbom_Returns200(t *testing.T) {
 	}))
 	defer server.Close()
 
-	sbomPath := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX"}`))
-
-	_, err := uploadSbom(server.URL, "test-key", "my-project", "my-parent", "1.0.0", sbomPath, "", false, noRetryClient())
+	_, err := uploadSbom(context.Background(), server.URL, "my-project", "my-parent", "1.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "", false, noRetryClient())
 	if err != nil {
 		t.Errorf("expected nil error, got: %v", err)
 	}
@@ -78,9 +269,7 @@ func TestUploadSbom_400ReturnsError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	sbomPath := writeTempSbom(t, []byte(`THIS IS NOT JSON`))
-
-	_, err := uploadSbom(server.URL, "test-key", "my-project", "my-parent", "1.0.0", sbomPath, "", false, noRetryClient())
+	_, err := uploadSbom(context.Background(), server.URL, "my-project", "my-parent", "1.0.0", []byte(`THIS IS NOT JSON`), "", false, noRetryClient())
 	if err == nil {
 		t.Error("expected error for HTTP 400, got nil")
 	}
@@ -92,9 +281,7 @@ func TestUploadSbom_500ReturnsError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	sbomPath := writeTempSbom(t, []byte(`{}`))
-
-	_, err := uploadSbom(server.URL, "test-key", "my-project", "my-parent", "1.0.0", sbomPath, "", false, noRetryClient())
+	_, err := uploadSbom(context.Background(), server.URL, "my-project", "my-parent", "1.0.0", []byte(`{}`), "", false, noRetryClient())
 	if err == nil {
 		t.Error("expected error for HTTP 500, got nil")
 	}
@@ -116,9 +303,7 @@ func TestUploadSbom_SendsFormFields(t *testing.T) {
 	}))
 	defer server.Close()
 
-	sbomPath := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX"}`))
-
-	_, err := uploadSbom(server.URL, "test-key", "my-project", "my-parent", "2.0.0", sbomPath, "tag1,tag2", false, noRetryClient())
+	_, err := uploadSbom(context.Background(), server.URL, "my-project", "my-parent", "2.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "tag1,tag2", false, noRetryClient())
 	if err != nil {
 		t.Fatalf("uploadSbom returned unexpected error: %v", err)
 	}
@@ -152,9 +337,7 @@ func TestUploadSbom_IsLatestFieldSentWhenTrue(t *testing.T) {
 	}))
 	defer server.Close()
 
-	sbomPath := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX"}`))
-
-	_, err := uploadSbom(server.URL, "test-key", "my-project", "my-parent", "1.0.0", sbomPath, "", true, noRetryClient())
+	_, err := uploadSbom(context.Background(), server.URL, "my-project", "my-parent", "1.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "", true, noRetryClient())
 	if err != nil {
 		t.Fatalf("uploadSbom returned unexpected error: %v", err)
 	}
@@ -175,9 +358,7 @@ func TestUploadSbom_IsLatestFieldAbsentWhenFalse(t *testing.T) {
 	}))
 	defer server.Close()
 
-	sbomPath := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX"}`))
-
-	_, err := uploadSbom(server.URL, "test-key", "my-project", "my-parent", "1.0.0", sbomPath, "", false, noRetryClient())
+	_, err := uploadSbom(context.Background(), server.URL, "my-project", "my-parent", "1.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "", false, noRetryClient())
 	if err != nil {
 		t.Fatalf("uploadSbom returned unexpected error: %v", err)
 	}
@@ -186,8 +367,8 @@ func TestUploadSbom_IsLatestFieldAbsentWhenFalse(t *testing.T) {
 	}
 }
 
-func TestUploadSbom_MissingFileReturnsError(t *testing.T) {
-	_, err := uploadSbom("http://localhost", "key", "proj", "parent", "1.0", "/nonexistent/path.json", "", false, noRetryClient())
+func TestReadSbom_MissingFileReturnsError(t *testing.T) {
+	_, err := readSbom("/nonexistent/path.json")
 	if err == nil {
 		t.Error("expected error for missing file, got nil")
 	}
@@ -200,9 +381,7 @@ func TestUploadSbom_ReturnsToken(t *testing.T) {
 	}))
 	defer server.Close()
 
-	sbomPath := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX"}`))
-
-	token, err := uploadSbom(server.URL, "test-key", "my-project", "my-parent", "1.0.0", sbomPath, "", false, noRetryClient())
+	token, err := uploadSbom(context.Background(), server.URL, "my-project", "my-parent", "1.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "", false, noRetryClient())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -218,7 +397,7 @@ func TestEnsureParentExists_ParentFoundSkipsCreation(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/v1/project/lookup", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(Project{Name: "existing-parent"})
+		_ = json.NewEncoder(w).Encode(Project{UUID: "parent-1", Name: "existing-parent"})
 	})
 	mux.HandleFunc("/api/v1/project", func(w http.ResponseWriter, r *http.Request) {
 		putCalled = true
@@ -227,10 +406,13 @@ func TestEnsureParentExists_ParentFoundSkipsCreation(t *testing.T) {
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	err := ensureParentExists(server.URL, "test-key", "existing-parent", "", noRetryClient())
+	uuid, created, err := ensureParentExists(context.Background(), server.URL, "existing-parent", "", noRetryClient())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if uuid != "parent-1" || created {
+		t.Errorf("got uuid %q, created %v; want parent-1, false", uuid, created)
+	}
 	if putCalled {
 		t.Error("createParent was called even though parent already exists")
 	}
@@ -245,15 +427,18 @@ func TestEnsureParentExists_ParentNotFoundCreatesIt(t *testing.T) {
 	mux.HandleFunc("/api/v1/project", func(w http.ResponseWriter, r *http.Request) {
 		putCalled = true
 		w.WriteHeader(http.StatusCreated)
-		_ = json.NewEncoder(w).Encode(Project{Name: "new-parent"})
+		_ = json.NewEncoder(w).Encode(Project{UUID: "parent-2", Name: "new-parent"})
 	})
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	err := ensureParentExists(server.URL, "test-key", "new-parent", "", noRetryClient())
+	uuid, created, err := ensureParentExists(context.Background(), server.URL, "new-parent", "", noRetryClient())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if uuid != "parent-2" || !created {
+		t.Errorf("got uuid %q, created %v; want parent-2, true", uuid, created)
+	}
 	if !putCalled {
 		t.Error("createParent was not called for missing parent")
 	}
@@ -266,7 +451,7 @@ func TestEnsureParentExists_LookupErrorReturnsError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	err := ensureParentExists(server.URL, "bad-key", "my-parent", "", noRetryClient())
+	_, _, err := ensureParentExists(context.Background(), server.URL, "my-parent", "", noRetryClient())
 	if err == nil {
 		t.Error("expected error for HTTP 401, got nil")
 	}
@@ -288,7 +473,7 @@ func TestCreateParent_SendsCorrectPayload(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_, err := createParent(server.URL, "test-key", "my-parent", "team-a,team-b", noRetryClient())
+	_, err := createParent(context.Background(), server.URL, "my-parent", "team-a,team-b", noRetryClient())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -316,7 +501,7 @@ func TestCreateParent_NonCreatedStatusReturnsError(t *testing.T) {
 			}))
 			defer server.Close()
 
-			_, err := createParent(server.URL, "test-key", "my-parent", "", noRetryClient())
+			_, err := createParent(context.Background(), server.URL, "my-parent", "", noRetryClient())
 			if err == nil {
 				t.Errorf("expected error for status %d, got nil", status)
 			}
@@ -332,7 +517,7 @@ func TestPollImport_ReturnsWhenProcessingFalse(t *testing.T) {
 	}))
 	defer server.Close()
 
-	err := pollImport(server.URL, "test-key", "test-token", noRetryClient(), 0)
+	err := pollImport(context.Background(), server.URL, "test-token", noRetryClient(), 0, time.Minute)
 	if err != nil {
 		t.Errorf("expected nil error, got: %v", err)
 	}
@@ -347,7 +532,7 @@ func TestPollImport_PollsUntilProcessingFalse(t *testing.T) {
 	}))
 	defer server.Close()
 
-	err := pollImport(server.URL, "test-key", "test-token", noRetryClient(), 0)
+	err := pollImport(context.Background(), server.URL, "test-token", noRetryClient(), 0, time.Minute)
 	if err != nil {
 		t.Fatalf("expected nil error, got: %v", err)
 	}
@@ -364,7 +549,7 @@ func TestPollImport_UsesCorrectTokenInURL(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_ = pollImport(server.URL, "test-key", "abc-123", noRetryClient(), 0)
+	_ = pollImport(context.Background(), server.URL, "abc-123", noRetryClient(), 0, time.Minute)
 
 	if gotPath != "/api/v1/bom/token/abc-123" {
 		t.Errorf("path: got %q, want %q", gotPath, "/api/v1/bom/token/abc-123")
@@ -384,7 +569,7 @@ func TestPollImport_TimesOut(t *testing.T) {
 	// to force a request failure instead.
 	server.Close()
 
-	err := pollImport(server.URL, "test-key", "test-token", noRetryClient(), 0)
+	err := pollImport(context.Background(), server.URL, "test-token", noRetryClient(), 0, time.Minute)
 	if err == nil {
 		t.Error("expected error when server is unreachable, got nil")
 	}
@@ -398,7 +583,7 @@ func TestPollImport_SetsApiKeyHeader(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_ = pollImport(server.URL, "my-api-key", "test-token", noRetryClient(), 0)
+	_ = pollImport(context.Background(), server.URL, "test-token", noRetryClientWithKey("my-api-key"), 0, time.Minute)
 
 	if gotKey != "my-api-key" {
 		t.Errorf("X-Api-Key: got %q, want %q", gotKey, "my-api-key")
@@ -412,11 +597,67 @@ func TestPollImport_NonOKStatusReturnsError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	err := pollImport(server.URL, "bad-key", "test-token", noRetryClient(), 0)
+	err := pollImport(context.Background(), server.URL, "test-token", noRetryClient(), 0, time.Minute)
 	if err == nil {
 		t.Error("expected error for HTTP 401, got nil")
 	}
 }
 
-// Ensure time import is used (interval parameter in tests)
-var _ = time.Second
+func TestPollImport_TimeoutReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]bool{"processing": true})
+	}))
+	defer server.Close()
+
+	err := pollImport(context.Background(), server.URL, "test-token", noRetryClient(), 10*time.Millisecond, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got: %v", err)
+	}
+}
+
+func TestPollImport_CancelledContextStopsPromptly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]bool{"processing": true})
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := pollImport(ctx, server.URL, "test-token", noRetryClient(), time.Minute, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("pollImport took %s to notice cancellation", elapsed)
+	}
+}
+
+func TestNextPollInterval_BacksOffToCap(t *testing.T) {
+	tests := []struct {
+		in, want time.Duration
+	}{
+		{0, 0},
+		{2 * time.Second, 4 * time.Second},
+		{20 * time.Second, maxPollInterval},
+		{maxPollInterval, maxPollInterval},
+		{time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := nextPollInterval(tt.in); got != tt.want {
+			t.Errorf("nextPollInterval(%s): got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJitter_StaysWithinBounds(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if got := jitter(time.Second); got < 500*time.Millisecond || got > time.Second {
+			t.Fatalf("jitter(1s) = %s, want within [500ms, 1s]", got)
+		}
+	}
+	if got := jitter(0); got != 0 {
+		t.Errorf("jitter(0) = %s, want 0", got)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// refreshMetrics asks Dependency-Track to recalculate a project's metrics and
+// waits until they were calculated after the request. A finished BOM token
+// only means the BOM was ingested; until metrics are refreshed they still
+// describe the previous state of the project.
+//
+// Progress is judged by the server's lastOccurrence before the refresh rather
+// than the local clock, which may be skewed against the server's.
+func refreshMetrics(ctx context.Context, dependencyTrackUrl string, projectUUID string, client *retryablehttp.Client, interval time.Duration, timeout time.Duration) error {
+	previous, err := fetchCurrentMetrics(ctx, dependencyTrackUrl, projectUUID, client)
+	if err != nil {
+		return err
+	}
+	base := fmt.Sprintf("%s/api/v1/metrics/project/%s", strings.TrimRight(dependencyTrackUrl, "/"), projectUUID)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", base+"/refresh", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("metrics refresh failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	return pollUntil(ctx, "metrics refresh", interval, timeout, func(ctx context.Context) (bool, error) {
+		metrics, err := fetchCurrentMetrics(ctx, dependencyTrackUrl, projectUUID, client)
+		if err != nil {
+			return false, err
+		}
+		if metrics.LastOccurrence <= previous.LastOccurrence {
+			slog.Info("Metrics not refreshed yet, waiting...")
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+func fetchCurrentMetrics(ctx context.Context, dependencyTrackUrl string, projectUUID string, client *retryablehttp.Client) (*Metrics, error) {
+	url := fmt.Sprintf("%s/api/v1/metrics/project/%s/current", strings.TrimRight(dependencyTrackUrl, "/"), projectUUID)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch project metrics, status %d: %s", resp.StatusCode, respBody)
+	}
+
+	// Dependency-Track returns an empty body when no metrics exist yet.
+	var metrics Metrics
+	if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse project metrics response: %w", err)
+	}
+	return &metrics, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// --- refreshMetrics ---
+
+func TestRefreshMetrics_WaitsForMetricsNewerThanBeforeRefresh(t *testing.T) {
+	// The server's clock is an hour behind, so its timestamps are all older
+	// than the local time of the upload.
+	serverNow := time.Now().Add(-time.Hour)
+	var refreshed atomic.Bool
+	var currentCalls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/metrics/project/abc-123/refresh", func(w http.ResponseWriter, r *http.Request) {
+		refreshed.Store(true)
+	})
+	mux.HandleFunc("/api/v1/metrics/project/abc-123/current", func(w http.ResponseWriter, r *http.Request) {
+		// Stale metrics before the refresh and for two polls, then recalculated ones.
+		last := serverNow
+		if currentCalls.Add(1) > 3 {
+			last = serverNow.Add(time.Second)
+		}
+		_ = json.NewEncoder(w).Encode(Metrics{Components: 3, LastOccurrence: last.UnixMilli()})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	err := refreshMetrics(context.Background(), server.URL, "abc-123", noRetryClient(), 0, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !refreshed.Load() {
+		t.Error("refresh endpoint was not called")
+	}
+	if currentCalls.Load() != 4 {
+		t.Errorf("expected 4 metrics requests, got %d", currentCalls.Load())
+	}
+}
+
+func TestRefreshMetrics_EmptyMetricsKeepWaiting(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/metrics/project/abc-123/refresh", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/api/v1/metrics/project/abc-123/current", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	err := refreshMetrics(context.Background(), server.URL, "abc-123", noRetryClient(), 10*time.Millisecond, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out waiting for metrics refresh") {
+		t.Errorf("expected metrics refresh timeout, got: %v", err)
+	}
+}
+
+func TestRefreshMetrics_RefreshErrorReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := refreshMetrics(context.Background(), server.URL, "abc-123", noRetryClient(), 0, time.Minute)
+	if err == nil {
+		t.Error("expected error for HTTP 403, got nil")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// uploadMetrics describes one upload for --metrics-file.
+type uploadMetrics struct {
+	Project string
+	Version string
+	Parent  string
+
+	UploadedAt     time.Time
+	UploadDuration time.Duration
+	// ImportWait is the time spent polling for the import, zero without --poll.
+	ImportWait time.Duration
+	Retries    int
+	SBOMSize   int
+	// SBOMComponents is the number of components in the SBOM file, or -1 if it
+	// isn't CycloneDX JSON.
+	SBOMComponents int
+	// Imported holds the project metrics after import, nil without --poll.
+	Imported *Metrics
+}
+
+// writeMetricsFile writes m in the OpenMetrics text format. The file is
+// replaced atomically, so the node_exporter textfile collector never reads a
+// partial file.
+func writeMetricsFile(path string, m uploadMetrics) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := formatMetrics(tmp, m); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	// The collector runs as a different user than most CI agents.
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return nil
+}
+
+func formatMetrics(w io.Writer, m uploadMetrics) error {
+	labels := fmt.Sprintf(`project="%s",version="%s",parent="%s"`,
+		escapeLabelValue(m.Project), escapeLabelValue(m.Version), escapeLabelValue(m.Parent))
+	var sb strings.Builder
+	gauge := func(name string, unit string, help string, samples ...string) {
+		fmt.Fprintf(&sb, "# TYPE %s gauge\n", name)
+		if unit != "" {
+			fmt.Fprintf(&sb, "# UNIT %s %s\n", name, unit)
+		}
+		fmt.Fprintf(&sb, "# HELP %s %s\n", name, help)
+		for _, s := range samples {
+			sb.WriteString(s)
+		}
+	}
+	sample := func(name string, extraLabels string, value any) string {
+		return fmt.Sprintf("%s{%s%s} %v\n", name, labels, extraLabels, value)
+	}
+
+	gauge("sbom_uploader_last_upload_timestamp_seconds", "seconds", "Time the SBOM was uploaded.",
+		sample("sbom_uploader_last_upload_timestamp_seconds", "", m.UploadedAt.Unix()))
+	gauge("sbom_uploader_upload_duration_seconds", "seconds", "Time taken by the SBOM upload request, retries included.",
+		sample("sbom_uploader_upload_duration_seconds", "", m.UploadDuration.Seconds()))
+	if m.ImportWait > 0 {
+		gauge("sbom_uploader_import_wait_seconds", "seconds", "Time spent waiting for Dependency-Track to import the SBOM.",
+			sample("sbom_uploader_import_wait_seconds", "", m.ImportWait.Seconds()))
+	}
+	gauge("sbom_uploader_retries", "", "Number of retried HTTP requests during the run.",
+		sample("sbom_uploader_retries", "", m.Retries))
+	gauge("sbom_uploader_sbom_size_bytes", "bytes", "Size of the uploaded SBOM.",
+		sample("sbom_uploader_sbom_size_bytes", "", m.SBOMSize))
+	switch {
+	case m.Imported != nil:
+		gauge("sbom_uploader_components", "", "Number of components in the project.",
+			sample("sbom_uploader_components", "", m.Imported.Components))
+	case m.SBOMComponents >= 0:
+		gauge("sbom_uploader_components", "", "Number of components in the project.",
+			sample("sbom_uploader_components", "", m.SBOMComponents))
+	}
+	if m.Imported != nil {
+		var samples []string
+		for _, c := range []struct {
+			severity string
+			count    int
+		}{
+			{"critical", m.Imported.Critical},
+			{"high", m.Imported.High},
+			{"medium", m.Imported.Medium},
+			{"low", m.Imported.Low},
+			{"unassigned", m.Imported.Unassigned},
+		} {
+			samples = append(samples, sample("sbom_uploader_vulnerabilities", fmt.Sprintf(`,severity="%s"`, c.severity), c.count))
+		}
+		gauge("sbom_uploader_vulnerabilities", "", "Number of vulnerabilities in the project by severity.", samples...)
+	}
+	sb.WriteString("# EOF\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// escapeLabelValue escapes a label value for the text exposition format.
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatMetrics_AfterImport(t *testing.T) {
+	var sb strings.Builder
+	err := formatMetrics(&sb, uploadMetrics{
+		Project:        "svc",
+		Version:        "1.0.0",
+		Parent:         "platform",
+		UploadedAt:     time.Unix(1700000000, 0),
+		UploadDuration: 1500 * time.Millisecond,
+		ImportWait:     4 * time.Second,
+		Retries:        2,
+		SBOMSize:       1024,
+		SBOMComponents: 3,
+		Imported:       &Metrics{Components: 5, Vulnerabilities: 4, Critical: 1, High: 2, Low: 1},
+	})
+	if err != nil {
+		t.Fatalf("formatMetrics: %v", err)
+	}
+	want := `# TYPE sbom_uploader_last_upload_timestamp_seconds gauge
+# UNIT sbom_uploader_last_upload_timestamp_seconds seconds
+# HELP sbom_uploader_last_upload_timestamp_seconds Time the SBOM was uploaded.
+sbom_uploader_last_upload_timestamp_seconds{project="svc",version="1.0.0",parent="platform"} 1700000000
+# TYPE sbom_uploader_upload_duration_seconds gauge
+# UNIT sbom_uploader_upload_duration_seconds seconds
+# HELP sbom_uploader_upload_duration_seconds Time taken by the SBOM upload request, retries included.
+sbom_uploader_upload_duration_seconds{project="svc",version="1.0.0",parent="platform"} 1.5
+# TYPE sbom_uploader_import_wait_seconds gauge
+# UNIT sbom_uploader_import_wait_seconds seconds
+# HELP sbom_uploader_import_wait_seconds Time spent waiting for Dependency-Track to import the SBOM.
+sbom_uploader_import_wait_seconds{project="svc",version="1.0.0",parent="platform"} 4
+# TYPE sbom_uploader_retries gauge
+# HELP sbom_uploader_retries Number of retried HTTP requests during the run.
+sbom_uploader_retries{project="svc",version="1.0.0",parent="platform"} 2
+# TYPE sbom_uploader_sbom_size_bytes gauge
+# UNIT sbom_uploader_sbom_size_bytes bytes
+# HELP sbom_uploader_sbom_size_bytes Size of the uploaded SBOM.
+sbom_uploader_sbom_size_bytes{project="svc",version="1.0.0",parent="platform"} 1024
+# TYPE sbom_uploader_components gauge
+# HELP sbom_uploader_components Number of components in the project.
+sbom_uploader_components{project="svc",version="1.0.0",parent="platform"} 5
+# TYPE sbom_uploader_vulnerabilities gauge
+# HELP sbom_uploader_vulnerabilities Number of vulnerabilities in the project by severity.
+sbom_uploader_vulnerabilities{project="svc",version="1.0.0",parent="platform",severity="critical"} 1
+sbom_uploader_vulnerabilities{project="svc",version="1.0.0",parent="platform",severity="high"} 2
+sbom_uploader_vulnerabilities{project="svc",version="1.0.0",parent="platform",severity="medium"} 0
+sbom_uploader_vulnerabilities{project="svc",version="1.0.0",parent="platform",severity="low"} 1
+sbom_uploader_vulnerabilities{project="svc",version="1.0.0",parent="platform",severity="unassigned"} 0
+# EOF
+`
+	if got := sb.String(); got != want {
+		t.Errorf("unexpected metrics:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatMetrics_WithoutPoll(t *testing.T) {
+	var sb strings.Builder
+	if err := formatMetrics(&sb, uploadMetrics{Project: "svc", SBOMSize: 10, SBOMComponents: 3}); err != nil {
+		t.Fatalf("formatMetrics: %v", err)
+	}
+	got := sb.String()
+	if !strings.Contains(got, `sbom_uploader_components{project="svc",version="",parent=""} 3`) {
+		t.Errorf("expected the SBOM's component count without --poll, got:\n%s", got)
+	}
+	for _, absent := range []string{"sbom_uploader_import_wait_seconds", "sbom_uploader_vulnerabilities"} {
+		if strings.Contains(got, absent) {
+			t.Errorf("expected no %s without --poll, got:\n%s", absent, got)
+		}
+	}
+}
+
+func TestFormatMetrics_EscapesLabelValues(t *testing.T) {
+	var sb strings.Builder
+	if err := formatMetrics(&sb, uploadMetrics{Project: `a "quoted" \ name` + "\n", SBOMComponents: -1}); err != nil {
+		t.Fatalf("formatMetrics: %v", err)
+	}
+	if want := `project="a \"quoted\" \\ name\n"`; !strings.Contains(sb.String(), want) {
+		t.Errorf("expected escaped label %s, got:\n%s", want, sb.String())
+	}
+	if strings.Contains(sb.String(), "sbom_uploader_components") {
+		t.Error("expected no component count for an SBOM that couldn't be parsed")
+	}
+}
+
+func TestWriteMetricsFile_ReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sbom.prom")
+	if err := os.WriteFile(path, []byte("stale"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeMetricsFile(path, uploadMetrics{Project: "svc", SBOMSize: 42}); err != nil {
+		t.Fatalf("writeMetricsFile: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `sbom_uploader_sbom_size_bytes{project="svc",version="",parent=""} 42`) || !strings.HasSuffix(string(b), "# EOF\n") {
+		t.Errorf("unexpected metrics file:\n%s", b)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("expected the metrics file to be world-readable, got %v", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+type NotificationRule struct {
+	UUID     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Projects []Project `json:"projects,omitempty"`
+}
+
+// systemConfigurationError explains a 403 from the notification rule
+// endpoints, which need the SYSTEM_CONFIGURATION permission.
+func systemConfigurationError(what string) error {
+	return fmt.Errorf("%s failed with status 403: the API key needs the SYSTEM_CONFIGURATION permission to use --notification-rule", what)
+}
+
+// resolveNotificationRules returns the notification rules with the given
+// names.
+func resolveNotificationRules(ctx context.Context, dependencyTrackUrl string, names []string, client *retryablehttp.Client) ([]NotificationRule, error) {
+	all, err := fetchPaged[NotificationRule](ctx, dependencyTrackUrl, "/api/v1/notification/rule", nil, client)
+	if err != nil {
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusForbidden {
+			return nil, systemConfigurationError("listing notification rules")
+		}
+		return nil, err
+	}
+	var rules []NotificationRule
+	for _, name := range names {
+		i := slices.IndexFunc(all, func(r NotificationRule) bool { return r.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("notification rule %q not found", name)
+		}
+		rules = append(rules, all[i])
+	}
+	return rules, nil
+}
+
+// subscribeToNotificationRules adds the project to each rule it isn't
+// already part of.
+func subscribeToNotificationRules(ctx context.Context, dependencyTrackUrl string, projectUUID string, rules []NotificationRule, client *retryablehttp.Client) error {
+	for _, rule := range rules {
+		if slices.ContainsFunc(rule.Projects, func(p Project) bool { return p.UUID == projectUUID }) {
+			slog.Debug("Project already subscribed to notification rule.", "rule", rule.Name, "uuid", projectUUID)
+			continue
+		}
+		url := fmt.Sprintf("%s/api/v1/notification/rule/%s/project/%s", strings.TrimRight(dependencyTrackUrl, "/"), rule.UUID, projectUUID)
+		req, err := retryablehttp.NewRequestWithContext(ctx, "POST", url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		// 304 Not Modified means the project was already part of the rule.
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusNotModified:
+		case http.StatusForbidden:
+			return systemConfigurationError("subscribing to notification rule " + rule.Name)
+		default:
+			return fmt.Errorf("subscribing to notification rule %s failed with status %d: %s", rule.Name, resp.StatusCode, respBody)
+		}
+		slog.Info("Project subscribed to notification rule.", "rule", rule.Name, "uuid", projectUUID)
+	}
+	return nil
+}
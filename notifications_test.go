@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+func TestSubscribeToNotificationRules_Idempotent(t *testing.T) {
+	fake := fakedtrack.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	project := fake.AddProject(fakedtrack.Project{Name: "svc", Version: "1.0.0"})
+	slack := fake.AddNotificationRule("Slack critical")
+	fake.AddNotificationRule("Webhook")
+	ctx := context.Background()
+	client := noRetryClient()
+
+	for run := 1; run <= 2; run++ {
+		rules, err := resolveNotificationRules(ctx, server.URL, []string{"Slack critical"}, client)
+		if err != nil {
+			t.Fatalf("run %d: resolveNotificationRules: %v", run, err)
+		}
+		if err := subscribeToNotificationRules(ctx, server.URL, project, rules, client); err != nil {
+			t.Fatalf("run %d: subscribeToNotificationRules: %v", run, err)
+		}
+	}
+
+	if got := fake.NotificationRuleProjects(slack); !slices.Equal(got, []string{project}) {
+		t.Errorf("expected the project to be subscribed once, got %v", got)
+	}
+	subscribes := 0
+	for _, r := range fake.Requests() {
+		if strings.HasPrefix(r, "POST /api/v1/notification/rule/") {
+			subscribes++
+		}
+	}
+	if subscribes != 1 {
+		t.Errorf("expected an already subscribed project to be skipped, got %d subscribe requests", subscribes)
+	}
+}
+
+func TestSubscribeToNotificationRules_NotModifiedIsSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	rules := []NotificationRule{{UUID: "rule-1", Name: "Slack"}}
+	if err := subscribeToNotificationRules(context.Background(), server.URL, "p-1", rules, noRetryClient()); err != nil {
+		t.Errorf("expected 304 to count as already subscribed, got %v", err)
+	}
+}
+
+func TestResolveNotificationRules_Errors(t *testing.T) {
+	fake := fakedtrack.New()
+	fake.AddNotificationRule("Slack")
+	fake.InjectFailure(fakedtrack.Failure{Method: "GET", Path: "/api/v1/notification/rule", Status: http.StatusForbidden, Count: 1})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	ctx := context.Background()
+
+	_, err := resolveNotificationRules(ctx, server.URL, []string{"Slack"}, noRetryClient())
+	if err == nil || !strings.Contains(err.Error(), "SYSTEM_CONFIGURATION") {
+		t.Errorf("expected an error naming SYSTEM_CONFIGURATION, got %v", err)
+	}
+	_, err = resolveNotificationRules(ctx, server.URL, []string{"Teams"}, noRetryClient())
+	if err == nil || !strings.Contains(err.Error(), `notification rule "Teams" not found`) {
+		t.Errorf("expected a rule not found error, got %v", err)
+	}
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// osvVulnerability is the subset of the OSV schema
+// (https://ossf.github.io/osv-schema/) used for matching.
+type osvVulnerability struct {
+	ID               string        `json:"id"`
+	Aliases          []string      `json:"aliases"`
+	Withdrawn        string        `json:"withdrawn"`
+	Affected         []osvAffected `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []osvRange `json:"ranges"`
+	Versions []string   `json:"versions"`
+	// GitHub advisories carry the severity here for some ecosystems.
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+	EcosystemSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"ecosystem_specific"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+// osvEvent sets exactly one of its fields.
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// purlEcosystems maps package URL types to OSV ecosystems. Distribution
+// packages aren't supported, as their versions depend on the release.
+var purlEcosystems = map[string]string{
+	"cargo":    "crates.io",
+	"composer": "Packagist",
+	"gem":      "RubyGems",
+	"golang":   "Go",
+	"hex":      "Hex",
+	"maven":    "Maven",
+	"npm":      "npm",
+	"nuget":    "NuGet",
+	"pub":      "Pub",
+	"pypi":     "PyPI",
+}
+
+// osvPackage identifies a package across the SBOM and the OSV database.
+type osvPackage struct {
+	Ecosystem string
+	Name      string
+}
+
+func newOSVPackage(ecosystem string, name string) osvPackage {
+	// Ecosystems may carry a release suffix, e.g. "Debian:12".
+	ecosystem, _, _ = strings.Cut(ecosystem, ":")
+	switch ecosystem {
+	case "PyPI":
+		// PEP 503 name normalization.
+		name = strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(name))
+		for strings.Contains(name, "--") {
+			name = strings.ReplaceAll(name, "--", "-")
+		}
+	case "NuGet", "Packagist":
+		name = strings.ToLower(name)
+	}
+	return osvPackage{Ecosystem: ecosystem, Name: name}
+}
+
+// purlPackage returns the OSV package and version a package URL refers to.
+// ok is false for package types OSV matching doesn't support.
+func purlPackage(purl string) (pkg osvPackage, version string, ok bool) {
+	rest, found := strings.CutPrefix(purl, "pkg:")
+	if !found {
+		return osvPackage{}, "", false
+	}
+	if i := strings.IndexAny(rest, "?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.LastIndex(rest, "@"); i > strings.LastIndex(rest, "/") {
+		rest, version = rest[:i], rest[i+1:]
+	}
+	typ, path, found := strings.Cut(rest, "/")
+	ecosystem, supported := purlEcosystems[strings.ToLower(typ)]
+	if !found || !supported || version == "" {
+		return osvPackage{}, "", false
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			segments[i] = unescaped
+		}
+	}
+	if unescaped, err := url.PathUnescape(version); err == nil {
+		version = unescaped
+	}
+	name := strings.Join(segments, "/")
+	if ecosystem == "Maven" {
+		name = strings.Join(segments, ":")
+	}
+	return newOSVPackage(ecosystem, name), version, true
+}
+
+// osvDatabase indexes OSV records by the packages they affect.
+type osvDatabase map[osvPackage][]*osvVulnerability
+
+// loadOSVDatabase reads OSV records from path: a JSON file, a zip archive
+// such as the all.zip dumps from osv.dev, or a directory of either. Only
+// records affecting one of the wanted packages are kept.
+func loadOSVDatabase(path string, wanted map[osvPackage]bool) (osvDatabase, error) {
+	db := osvDatabase{}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OSV database: %w", err)
+	}
+	if !info.IsDir() {
+		if err := db.loadFile(path, wanted); err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ext := strings.ToLower(filepath.Ext(p)); ext != ".json" && ext != ".zip" {
+			return nil
+		}
+		return db.loadFile(p, wanted)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db osvDatabase) loadFile(path string, wanted map[osvPackage]bool) error {
+	if strings.ToLower(filepath.Ext(path)) != ".zip" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read OSV database: %w", err)
+		}
+		return db.add(path, content, wanted)
+	}
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open OSV database %s: %w", path, err)
+	}
+	defer func() { _ = archive.Close() }()
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || strings.ToLower(filepath.Ext(f.Name)) != ".json" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from %s: %w", f.Name, path, err)
+		}
+		content, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from %s: %w", f.Name, path, err)
+		}
+		if err := db.add(path+":"+f.Name, content, wanted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db osvDatabase) add(name string, content []byte, wanted map[osvPackage]bool) error {
+	var v osvVulnerability
+	if err := json.Unmarshal(content, &v); err != nil {
+		return fmt.Errorf("failed to parse OSV record %s: %w", name, err)
+	}
+	if v.Withdrawn != "" {
+		return nil
+	}
+	var added []osvPackage
+	for _, a := range v.Affected {
+		pkg := newOSVPackage(a.Package.Ecosystem, a.Package.Name)
+		if wanted[pkg] && !slices.Contains(added, pkg) {
+			db[pkg] = append(db[pkg], &v)
+			added = append(added, pkg)
+		}
+	}
+	return nil
+}
+
+// affects reports whether version of pkg is affected, either listed
+// explicitly or within one of the SEMVER or ECOSYSTEM ranges. GIT ranges
+// refer to commits and are ignored.
+func (a osvAffected) affects(pkg osvPackage, version string) bool {
+	if slices.Contains(a.Versions, version) {
+		return true
+	}
+	for _, r := range a.Ranges {
+		var compare func(a, b string) int
+		switch r.Type {
+		case "SEMVER":
+			compare = compareSemver
+		case "ECOSYSTEM":
+			compare = ecosystemComparator(pkg.Ecosystem)
+		default:
+			continue
+		}
+		if r.affects(version, compare) {
+			return true
+		}
+	}
+	return false
+}
+
+// affects evaluates the range's events in version order, as the OSV schema
+// specifies: a version is affected from an introduced event until a fixed or
+// limit event, or until after a last_affected event.
+func (r osvRange) affects(version string, compare func(a, b string) int) bool {
+	type event struct {
+		kind    string
+		version string
+	}
+	var events []event
+	for _, e := range r.Events {
+		switch {
+		case e.Introduced != "":
+			events = append(events, event{"introduced", e.Introduced})
+		case e.Fixed != "":
+			events = append(events, event{"fixed", e.Fixed})
+		case e.LastAffected != "":
+			events = append(events, event{"last_affected", e.LastAffected})
+		case e.Limit != "":
+			events = append(events, event{"limit", e.Limit})
+		}
+	}
+	// "0" stands for the first version of the package.
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i].version, events[j].version
+		if a == "0" || b == "0" {
+			return a == "0" && b != "0"
+		}
+		return compare(a, b) < 0
+	})
+	affected := false
+	for _, e := range events {
+		switch e.kind {
+		case "introduced":
+			if e.version == "0" || compare(version, e.version) >= 0 {
+				affected = true
+			}
+		case "fixed", "limit":
+			if compare(version, e.version) >= 0 {
+				affected = false
+			}
+		case "last_affected":
+			if compare(version, e.version) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// ecosystemComparator returns the version ordering of an ecosystem. Semantic
+// versioning ecosystems use compareSemver; the others use compareNatural,
+// which approximates Maven, PEP 440 and RubyGems ordering.
+func ecosystemComparator(ecosystem string) func(a, b string) int {
+	switch ecosystem {
+	case "Go", "npm", "crates.io", "Hex", "Pub", "NuGet":
+		return compareSemver
+	default:
+		return compareNatural
+	}
+}
+
+// compareSemver compares semantic versions, ignoring a leading "v" and build
+// metadata. Versions that aren't semantic versions fall back to
+// compareNatural.
+func compareSemver(a, b string) int {
+	ca, prea, okA := splitSemver(a)
+	cb, preb, okB := splitSemver(b)
+	if !okA || !okB {
+		return compareNatural(a, b)
+	}
+	for i := range ca {
+		if c := compareNumeric(ca[i], cb[i]); c != 0 {
+			return c
+		}
+	}
+	// A pre-release sorts before the release itself.
+	switch {
+	case prea == "" && preb == "":
+		return 0
+	case prea == "":
+		return 1
+	case preb == "":
+		return -1
+	}
+	pa, pb := strings.Split(prea, "."), strings.Split(preb, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, nb := isNumeric(pa[i]), isNumeric(pb[i])
+		var c int
+		switch {
+		case na && nb:
+			c = compareNumeric(pa[i], pb[i])
+		case na:
+			c = -1
+		case nb:
+			c = 1
+		default:
+			c = strings.Compare(pa[i], pb[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+func splitSemver(v string) (core [3]string, prerelease string, ok bool) {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "+")
+	v, prerelease, _ = strings.Cut(v, "-")
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 {
+		return core, "", false
+	}
+	core = [3]string{"0", "0", "0"}
+	for i, p := range parts {
+		if !isNumeric(p) {
+			return core, "", false
+		}
+		core[i] = p
+	}
+	return core, prerelease, true
+}
+
+// Qualifier ranks for compareNatural. Unknown qualifiers rank after a
+// release, like in Maven.
+var versionQualifiers = map[string]int{
+	"dev": 0, "alpha": 1, "a": 1, "beta": 2, "b": 2, "milestone": 3, "m": 3,
+	"rc": 4, "cr": 4, "c": 4, "pre": 4, "preview": 4, "snapshot": 5,
+	"": 6, "ga": 6, "final": 6, "release": 6, "sp": 7, "post": 7,
+}
+
+var versionSegmentPattern = regexp.MustCompile(`[0-9]+|[a-z]+`)
+
+// compareNatural compares versions segment by segment, numbers numerically
+// and words as pre- or post-release qualifiers. A number sorts after a
+// qualifier, so 1.0.1 > 1.0-rc1, and missing segments count as 0 or as a
+// release, so 1.0 == 1.0.0 and 1.0 > 1.0-beta.
+func compareNatural(a, b string) int {
+	sa, sb := versionSegments(a), versionSegments(b)
+	for i := 0; i < len(sa) || i < len(sb); i++ {
+		x, y := "", ""
+		if i < len(sa) {
+			x = sa[i]
+		}
+		if i < len(sb) {
+			y = sb[i]
+		}
+		nx, ny := isNumeric(x), isNumeric(y)
+		if x == "" && ny {
+			x, nx = "0", true
+		}
+		if y == "" && nx {
+			y, ny = "0", true
+		}
+		var c int
+		switch {
+		case nx && ny:
+			c = compareNumeric(x, y)
+		case nx:
+			c = 1
+		case ny:
+			c = -1
+		default:
+			c = compareQualifiers(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareQualifiers(a, b string) int {
+	ra, knownA := versionQualifiers[a]
+	rb, knownB := versionQualifiers[b]
+	if !knownA {
+		ra = len(versionQualifiers)
+	}
+	if !knownB {
+		rb = len(versionQualifiers)
+	}
+	if ra != rb || knownA {
+		return ra - rb
+	}
+	return strings.Compare(a, b)
+}
+
+// versionSegments splits a version into lower-case runs of digits and of
+// letters, dropping separators.
+func versionSegments(v string) []string {
+	return versionSegmentPattern.FindAllString(strings.ToLower(v), -1)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compareNumeric compares strings of digits of any length.
+func compareNumeric(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// severity returns the record's severity in Dependency-Track's terms,
+// from the GitHub advisory database's severity where present.
+func (v *osvVulnerability) severity(a osvAffected) string {
+	for _, s := range []string{v.DatabaseSpecific.Severity, a.DatabaseSpecific.Severity, a.EcosystemSpecific.Severity} {
+		switch s = strings.ToUpper(s); s {
+		case "MODERATE":
+			return "MEDIUM"
+		case "CRITICAL", "HIGH", "MEDIUM", "LOW":
+			return s
+		}
+	}
+	return "UNASSIGNED"
+}
+
+// matchOSV returns findings for the components whose package URL matches an
+// affected package and version in the database. Records that are aliases of
+// one already matched for a component, e.g. a GHSA and a PYSEC advisory for
+// the same CVE, are reported once.
+func matchOSV(components []cdxComponent, db osvDatabase) []Finding {
+	var findings []Finding
+	for _, c := range components {
+		pkg, version, ok := purlPackage(c.PURL)
+		if !ok {
+			continue
+		}
+		records := append([]*osvVulnerability(nil), db[pkg]...)
+		sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+		seen := map[string]bool{}
+		for _, v := range records {
+			i := slices.IndexFunc(v.Affected, func(a osvAffected) bool {
+				return newOSVPackage(a.Package.Ecosystem, a.Package.Name) == pkg && a.affects(pkg, version)
+			})
+			if i < 0 || seen[v.ID] || slices.ContainsFunc(v.Aliases, func(id string) bool { return seen[id] }) {
+				continue
+			}
+			seen[v.ID] = true
+			for _, id := range v.Aliases {
+				seen[id] = true
+			}
+			findings = append(findings, osvFinding(c, v, v.Affected[i]))
+		}
+	}
+	return findings
+}
+
+func osvFinding(c cdxComponent, v *osvVulnerability, a osvAffected) Finding {
+	vuln := FindingVulnerability{VulnID: v.ID, Source: "OSV", Severity: v.severity(a)}
+	for _, id := range v.Aliases {
+		switch {
+		case strings.HasPrefix(id, "CVE-"):
+			vuln.Aliases = append(vuln.Aliases, VulnerabilityAlias{CveID: id})
+		case strings.HasPrefix(id, "GHSA-"):
+			vuln.Aliases = append(vuln.Aliases, VulnerabilityAlias{GhsaID: id})
+		}
+	}
+	var fixed []string
+	for _, r := range a.Ranges {
+		for _, e := range r.Events {
+			if e.Fixed != "" && !slices.Contains(fixed, e.Fixed) {
+				fixed = append(fixed, e.Fixed)
+			}
+		}
+	}
+	vuln.PatchedVersions = strings.Join(fixed, ", ")
+	return Finding{
+		Component:     Component{Name: c.Name, Group: c.Group, Version: c.Version, PURL: c.PURL},
+		Vulnerability: vuln,
+	}
+}
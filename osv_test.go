@@ -0,0 +1,215 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPurlPackage(t *testing.T) {
+	tests := []struct {
+		purl    string
+		want    osvPackage
+		version string
+	}{
+		{"pkg:npm/%40angular/core@12.0.0", osvPackage{"npm", "@angular/core"}, "12.0.0"},
+		{"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1?type=jar", osvPackage{"Maven", "org.apache.logging.log4j:log4j-core"}, "2.14.1"},
+		{"pkg:golang/github.com/gin-gonic/gin@v1.6.0", osvPackage{"Go", "github.com/gin-gonic/gin"}, "v1.6.0"},
+		{"pkg:pypi/Django_REST.framework@3.11.0", osvPackage{"PyPI", "django-rest-framework"}, "3.11.0"},
+		{"pkg:cargo/smallvec@1.6.0", osvPackage{"crates.io", "smallvec"}, "1.6.0"},
+	}
+	for _, tt := range tests {
+		pkg, version, ok := purlPackage(tt.purl)
+		if !ok || pkg != tt.want || version != tt.version {
+			t.Errorf("%s: got %+v %q %t", tt.purl, pkg, version, ok)
+		}
+	}
+	for _, purl := range []string{"pkg:deb/debian/openssl@1.1.1", "pkg:npm/lodash", "lodash@4.17.20", ""} {
+		if _, _, ok := purlPackage(purl); ok {
+			t.Errorf("%q: expected no package", purl)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		compare func(a, b string) int
+		a, b    string
+		want    int
+	}{
+		{compareSemver, "1.2.3", "1.2.10", -1},
+		{compareSemver, "v1.2.3", "1.2.3", 0},
+		{compareSemver, "1.0.0-rc.1", "1.0.0", -1},
+		{compareSemver, "1.0.0-alpha.2", "1.0.0-alpha.10", -1},
+		{compareSemver, "1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{compareSemver, "1.0.0+build.5", "1.0.0", 0},
+		{compareSemver, "2.0", "1.99.99", 1},
+		{compareNatural, "2.14.1", "2.15.0", -1},
+		{compareNatural, "2.15.0-rc1", "2.15.0", -1},
+		{compareNatural, "1.0", "1.0.0", 0},
+		{compareNatural, "1.0.1", "1.0-rc1", 1},
+		{compareNatural, "3.2.0a1", "3.2.0b1", -1},
+		{compareNatural, "1.0.dev1", "1.0a1", -1},
+		{compareNatural, "1.0.post1", "1.0", 1},
+		{compareNatural, "5.3.18.RELEASE", "5.3.18", 0},
+		{compareNatural, "1.0-SNAPSHOT", "1.0", -1},
+		{compareNatural, "20230101", "9", 1},
+	}
+	for _, tt := range tests {
+		if got := sign(tt.compare(tt.a, tt.b)); got != tt.want {
+			t.Errorf("compare(%q, %q): got %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+const log4jOSV = `{
+  "id": "GHSA-jfh8-c2jp-5v3q",
+  "aliases": ["CVE-2021-44228"],
+  "database_specific": {"severity": "CRITICAL"},
+  "affected": [{
+    "package": {"ecosystem": "Maven", "name": "org.apache.logging.log4j:log4j-core"},
+    "ranges": [
+      {"type": "ECOSYSTEM", "events": [{"introduced": "2.13.0"}, {"fixed": "2.15.0"}]},
+      {"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "2.3.1"}]}
+    ]
+  }]
+}`
+
+func TestOSVRangeEvaluation(t *testing.T) {
+	db := osvDatabase{}
+	pkg := osvPackage{"Maven", "org.apache.logging.log4j:log4j-core"}
+	if err := db.add("log4j.json", []byte(log4jOSV), map[osvPackage]bool{pkg: true}); err != nil {
+		t.Fatal(err)
+	}
+	affected := db[pkg][0].Affected[0]
+	for version, want := range map[string]bool{
+		"2.0":        true,
+		"2.3.1":      false,
+		"2.12.4":     false,
+		"2.13.0":     true,
+		"2.14.1":     true,
+		"2.15.0-rc1": true,
+		"2.15.0":     false,
+		"2.17.1":     false,
+	} {
+		if got := affected.affects(pkg, version); got != want {
+			t.Errorf("%s: got %t, want %t", version, got, want)
+		}
+	}
+
+	// Events may be listed out of order.
+	lastAffected := osvAffected{
+		Ranges:   []osvRange{{Type: "SEMVER", Events: []osvEvent{{LastAffected: "1.4.0"}, {Introduced: "1.0.0"}}}},
+		Versions: []string{"0.9.0-beta"},
+	}
+	for version, want := range map[string]bool{"0.9.0": false, "0.9.0-beta": true, "1.0.0": true, "1.4.0": true, "1.4.1": false} {
+		if got := lastAffected.affects(osvPackage{"npm", "x"}, version); got != want {
+			t.Errorf("last_affected %s: got %t, want %t", version, got, want)
+		}
+	}
+}
+
+func writeOSVZip(t *testing.T, path string, records map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range records {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadOSVDatabaseAndMatch(t *testing.T) {
+	dir := t.TempDir()
+	for _, ecosystem := range []string{"Maven", "PyPI"} {
+		if err := os.MkdirAll(filepath.Join(dir, ecosystem), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeOSVZip(t, filepath.Join(dir, "Maven", "all.zip"), map[string]string{
+		"GHSA-jfh8-c2jp-5v3q.json": log4jOSV,
+		"GHSA-unrelated.json":      `{"id": "GHSA-unrelated", "affected": [{"package": {"ecosystem": "Maven", "name": "org.example:other"}}]}`,
+	})
+	// The same vulnerability in two databases is reported once.
+	for name, content := range map[string]string{
+		"PYSEC-2021-1.json": `{"id": "PYSEC-2021-1", "aliases": ["GHSA-aaaa-bbbb-cccc", "CVE-2021-1"],
+			"affected": [{"package": {"ecosystem": "PyPI", "name": "django"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "3.0"}, {"fixed": "3.1.2"}]}]}]}`,
+		"GHSA-aaaa-bbbb-cccc.json": `{"id": "GHSA-aaaa-bbbb-cccc", "aliases": ["CVE-2021-1"], "database_specific": {"severity": "MODERATE"},
+			"affected": [{"package": {"ecosystem": "PyPI", "name": "Django"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "3.0"}, {"fixed": "3.1.2"}]}]}]}`,
+		"withdrawn.json": `{"id": "PYSEC-2021-2", "withdrawn": "2021-06-01T00:00:00Z",
+			"affected": [{"package": {"ecosystem": "PyPI", "name": "django"}, "versions": ["3.1.0"]}]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "PyPI", name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	components := []cdxComponent{
+		{Name: "log4j-core", Version: "2.14.1", PURL: "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+		{Name: "django", Version: "3.1.0", PURL: "pkg:pypi/django@3.1.0"},
+		{Name: "django-fixed", Version: "3.1.2", PURL: "pkg:pypi/django@3.1.2"},
+		{Name: "no-purl", Version: "1.0"},
+	}
+	wanted := map[osvPackage]bool{}
+	for _, c := range components {
+		if pkg, _, ok := purlPackage(c.PURL); ok {
+			wanted[pkg] = true
+		}
+	}
+	db, err := loadOSVDatabase(dir, wanted)
+	if err != nil {
+		t.Fatalf("loadOSVDatabase: %v", err)
+	}
+	if _, ok := db[osvPackage{"Maven", "org.example:other"}]; ok {
+		t.Error("expected records for packages not in the SBOM to be skipped")
+	}
+
+	findings := matchOSV(components, db)
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Component.Name+"="+f.Vulnerability.VulnID+"/"+f.Vulnerability.Severity)
+	}
+	if want := "log4j-core=GHSA-jfh8-c2jp-5v3q/CRITICAL,django=GHSA-aaaa-bbbb-cccc/MEDIUM"; strings.Join(got, ",") != want {
+		t.Fatalf("got %v, want %s", got, want)
+	}
+	log4j := findings[0].Vulnerability
+	if ids := log4j.cveIDs(); len(ids) != 1 || ids[0] != "CVE-2021-44228" || log4j.PatchedVersions != "2.15.0, 2.3.1" {
+		t.Errorf("unexpected vulnerability: %+v", log4j)
+	}
+}
+
+func TestLoadOSVDatabase_InvalidRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadOSVDatabase(path, nil); err == nil || !strings.Contains(err.Error(), "broken.json") {
+		t.Errorf("expected an error naming the record, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const pageSize = 500
+
+// statusError is returned by fetchPaged for an unexpected response status.
+type statusError struct {
+	path   string
+	status int
+	body   []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("request to %s failed with status %d: %s", e.path, e.status, e.body)
+}
+
+// fetchPaged GETs every page of a paginated Dependency-Track list endpoint and
+// returns the concatenated results. Paging stops on a short page or, if the
+// server sends X-Total-Count, once that many items have been read.
+func fetchPaged[T any](ctx context.Context, dependencyTrackUrl string, path string, query url.Values, client *retryablehttp.Client) ([]T, error) {
+	var items []T
+	for page := 1; ; page++ {
+		batch, total, err := fetchPage[T](ctx, dependencyTrackUrl, path, query, page, client)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batch...)
+		if len(batch) < pageSize || total >= 0 && len(items) >= total {
+			return items, nil
+		}
+	}
+}
+
+// fetchPage returns one page and the X-Total-Count header, or -1 without one.
+func fetchPage[T any](ctx context.Context, dependencyTrackUrl string, path string, query url.Values, page int, client *retryablehttp.Client) ([]T, int, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("pageSize", strconv.Itoa(pageSize))
+	q.Set("pageNumber", strconv.Itoa(page))
+	url := fmt.Sprintf("%s%s?%s", strings.TrimRight(dependencyTrackUrl, "/"), path, q.Encode())
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, 0, &statusError{path: path, status: resp.StatusCode, body: respBody}
+	}
+
+	var items []T
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse %s response: %w", path, err)
+	}
+	total, err := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	if err != nil {
+		total = -1
+	}
+	return items, total, nil
+}
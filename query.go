@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/spf13/cobra"
+)
+
+func newProjectsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "projects",
+		Short: "Queries projects in Dependency-Track",
+	}
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Lists projects, optionally filtered by tag, name prefix, parent or active state",
+		RunE:  runProjectsList,
+	}
+	s := list.Flags()
+	setConnectionFlags(s)
+	s.String("tag", "", "Only list projects with this tag")
+	s.String("name-prefix", "", "Only list projects whose name starts with this prefix")
+	s.String("parent", "", "Only list children of this parent project name")
+	s.Bool("active", false, "Only list active projects (--active=false lists only inactive projects)")
+	s.String("format", "table", "Output format: table or json")
+	cmd.AddCommand(list)
+	return cmd
+}
+
+func newFindingsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "findings",
+		Short: "Lists the vulnerability findings of a project version",
+		RunE:  runFindings,
+	}
+	s := cmd.Flags()
+	setConnectionFlags(s)
+	s.String("name", "", "Project name or env SBOM_UPLOADER_NAME")
+	s.String("version", "", "Project version or env SBOM_UPLOADER_VERSION")
+	s.String("uuid", "", "Project UUID (instead of --name and --version)")
+	s.String("severity", "", "Comma-separated severities to include, e.g. CRITICAL,HIGH (default all)")
+	s.Bool("suppressed", false, "Include suppressed findings")
+	s.String("sort", "severity", "Sort order: severity, or epss for the most likely exploited first")
+	s.String("format", "table", "Output format: table or json")
+	return cmd
+}
+
+// projectFilter selects projects client-side. Active is nil when the active
+// state shouldn't be filtered on.
+type projectFilter struct {
+	Tag        string
+	NamePrefix string
+	Parent     string
+	Active     *bool
+}
+
+func (f projectFilter) matches(p Project) bool {
+	if f.Tag != "" && !slices.ContainsFunc(p.Tags, func(t Tag) bool { return strings.EqualFold(t.Name, f.Tag) }) {
+		return false
+	}
+	if f.NamePrefix != "" && !strings.HasPrefix(p.Name, f.NamePrefix) {
+		return false
+	}
+	if f.Parent != "" && (p.Parent == nil || p.Parent.Name != f.Parent) {
+		return false
+	}
+	if f.Active != nil && p.Active != *f.Active {
+		return false
+	}
+	return true
+}
+
+func runProjectsList(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	cfg, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.validateConnection(); err != nil {
+		return err
+	}
+	format, _ := cmd.Flags().GetString("format")
+	if err := validateTableFormat(format); err != nil {
+		return err
+	}
+	var filter projectFilter
+	filter.Tag, _ = cmd.Flags().GetString("tag")
+	filter.NamePrefix, _ = cmd.Flags().GetString("name-prefix")
+	filter.Parent, _ = cmd.Flags().GetString("parent")
+	if cmd.Flags().Changed("active") {
+		active, _ := cmd.Flags().GetBool("active")
+		filter.Active = &active
+	}
+
+	client, err := newDefaultRetryClient(cfg)
+	if err != nil {
+		return err
+	}
+	projects, err := listProjects(ctx, cfg.URL, filter, client)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		return writeJSON(cmd.OutOrStdout(), projects)
+	}
+	writeProjectsTable(cmd.OutOrStdout(), projects)
+	return nil
+}
+
+// listProjects fetches projects and applies filter. A tag filter is passed to
+// Dependency-Track to narrow the listing server-side; everything else is
+// matched locally.
+func listProjects(ctx context.Context, dependencyTrackUrl string, filter projectFilter, client *retryablehttp.Client) ([]Project, error) {
+	path := "/api/v1/project"
+	query := url.Values{}
+	if filter.Tag != "" {
+		path = "/api/v1/project/tag/" + url.PathEscape(filter.Tag)
+	}
+	if filter.NamePrefix != "" {
+		// The name parameter matches the exact name and searchText any part of
+		// it, ignoring case, so the prefix is enforced below.
+		query.Set("searchText", filter.NamePrefix)
+	}
+	if filter.Active != nil && *filter.Active {
+		query.Set("excludeInactive", "true")
+	}
+
+	all, err := fetchPaged[Project](ctx, dependencyTrackUrl, path, query, client)
+	if err != nil {
+		return nil, err
+	}
+	projects := []Project{}
+	for _, p := range all {
+		if filter.matches(p) {
+			projects = append(projects, p)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Name != projects[j].Name {
+			return projects[i].Name < projects[j].Name
+		}
+		return projects[i].Version < projects[j].Version
+	})
+	return projects, nil
+}
+
+func runFindings(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	cfg, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.validateConnection(); err != nil {
+		return err
+	}
+	uuid, _ := cmd.Flags().GetString("uuid")
+	severity, _ := cmd.Flags().GetString("severity")
+	suppressed, _ := cmd.Flags().GetBool("suppressed")
+	order, _ := cmd.Flags().GetString("sort")
+	format, _ := cmd.Flags().GetString("format")
+	if err := validateTableFormat(format); err != nil {
+		return err
+	}
+	if order != "severity" && order != "epss" {
+		return fmt.Errorf("invalid sort %q: must be severity or epss", order)
+	}
+	severities, err := parseSeverities(severity)
+	if err != nil {
+		return err
+	}
+
+	client, err := newDefaultRetryClient(cfg)
+	if err != nil {
+		return err
+	}
+	uuid, err = resolveProjectUUID(ctx, cfg, uuid, client)
+	if err != nil {
+		return err
+	}
+	findings, err := fetchFindings(ctx, cfg.URL, uuid, suppressed, client)
+	if err != nil {
+		return err
+	}
+	findings = filterFindingsBySeverity(findings, severities)
+	if order == "epss" {
+		sortFindingsByEPSS(findings)
+	} else {
+		sortFindings(findings)
+	}
+
+	if format == "json" {
+		return writeJSON(cmd.OutOrStdout(), findings)
+	}
+	writeFindingsTable(cmd.OutOrStdout(), findings)
+	return nil
+}
+
+func validateTableFormat(format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format %q: must be table or json", format)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeProjectsTable(w io.Writer, projects []Project) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tVERSION\tPARENT\tACTIVE\tTAGS\tUUID")
+	for _, p := range projects {
+		parent := ""
+		if p.Parent != nil {
+			parent = p.Parent.Name
+		}
+		tags := make([]string, 0, len(p.Tags))
+		for _, t := range p.Tags {
+			tags = append(tags, t.Name)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%s\n", p.Name, p.Version, parent, p.Active, strings.Join(tags, ","), p.UUID)
+	}
+	_ = tw.Flush()
+}
+
+func writeFindingsTable(w io.Writer, findings []Finding) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SEVERITY\tEPSS\tVULNERABILITY\tCOMPONENT\tVERSION\tANALYSIS\tSUPPRESSED")
+	for _, f := range findings {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%t\n",
+			f.Vulnerability.Severity, formatEPSS(f.Vulnerability.EPSSScore), f.Vulnerability.VulnID, componentKey(f.Component), f.Component.Version,
+			f.Analysis.State, f.Analysis.IsSuppressed)
+	}
+	_ = tw.Flush()
+}
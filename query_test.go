@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+// --- listProjects ---
+
+func TestListProjects_FiltersByPrefixParentAndActive(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/project" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		gotQuery = r.URL.RawQuery
+		_ = json.NewEncoder(w).Encode([]Project{
+			{Name: "svc-b", Version: "1.0", Active: true, Parent: &Project{Name: "platform"}},
+			{Name: "svc-a", Version: "2.0", Active: true, Parent: &Project{Name: "platform"}},
+			{Name: "svc-c", Version: "1.0", Active: false, Parent: &Project{Name: "platform"}},
+			{Name: "svc-d", Version: "1.0", Active: true, Parent: &Project{Name: "other"}},
+			{Name: "my-svc", Version: "1.0", Active: true, Parent: &Project{Name: "platform"}},
+		})
+	}))
+	defer server.Close()
+
+	active := true
+	filter := projectFilter{NamePrefix: "svc-", Parent: "platform", Active: &active}
+	projects, err := listProjects(context.Background(), server.URL, filter, noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, p := range projects {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "svc-a,svc-b" {
+		t.Errorf("projects: got %v, want [svc-a svc-b]", names)
+	}
+	if !strings.Contains(gotQuery, "excludeInactive=true") || !strings.Contains(gotQuery, "searchText=svc-") {
+		t.Errorf("query: got %q, want excludeInactive and searchText", gotQuery)
+	}
+}
+
+func TestListProjects_NamePrefixAgainstFake(t *testing.T) {
+	fake := fakedtrack.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	for _, name := range []string{"svc-b", "svc-a", "my-svc-c", "SVC-D", "svc"} {
+		fake.AddProject(fakedtrack.Project{Name: name, Version: "1.0", Active: true})
+	}
+
+	projects, err := listProjects(context.Background(), server.URL, projectFilter{NamePrefix: "svc-"}, noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, p := range projects {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "svc-a,svc-b" {
+		t.Errorf("projects: got %v, want [svc-a svc-b]", names)
+	}
+}
+
+func TestListProjects_TagUsesTagEndpoint(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = json.NewEncoder(w).Encode([]Project{{Name: "tagged", Tags: []Tag{{Name: "team-a"}}}})
+	}))
+	defer server.Close()
+
+	projects, err := listProjects(context.Background(), server.URL, projectFilter{Tag: "team-a"}, noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/api/v1/project/tag/team-a" {
+		t.Errorf("path: got %q, want %q", gotPath, "/api/v1/project/tag/team-a")
+	}
+	if len(projects) != 1 {
+		t.Errorf("projects: got %d, want 1", len(projects))
+	}
+}
+
+func TestListProjects_InactiveOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("excludeInactive") {
+			t.Errorf("excludeInactive should not be sent when listing inactive projects")
+		}
+		_ = json.NewEncoder(w).Encode([]Project{{Name: "old", Active: false}, {Name: "new", Active: true}})
+	}))
+	defer server.Close()
+
+	inactive := false
+	projects, err := listProjects(context.Background(), server.URL, projectFilter{Active: &inactive}, noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Name != "old" {
+		t.Errorf("projects: got %v, want [old]", projects)
+	}
+}
+
+// --- findings ---
+
+func TestFetchFindings_SendsSuppressedFlag(t *testing.T) {
+	var gotSuppressed string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/finding/project/abc-123" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		gotSuppressed = r.URL.Query().Get("suppressed")
+		_ = json.NewEncoder(w).Encode([]Finding{{Vulnerability: FindingVulnerability{VulnID: "CVE-1"}}})
+	}))
+	defer server.Close()
+
+	findings, err := fetchFindings(context.Background(), server.URL, "abc-123", true, noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotSuppressed != "true" {
+		t.Errorf("suppressed: got %q, want %q", gotSuppressed, "true")
+	}
+	if len(findings) != 1 {
+		t.Errorf("findings: got %d, want 1", len(findings))
+	}
+}
+
+func TestParseSeverities(t *testing.T) {
+	got, err := parseSeverities("critical, HIGH")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, ",") != "CRITICAL,HIGH" {
+		t.Errorf("got %v, want [CRITICAL HIGH]", got)
+	}
+	if _, err := parseSeverities("SEVERE"); err == nil {
+		t.Error("expected error for unknown severity, got nil")
+	}
+}
+
+func TestFilterAndSortFindings(t *testing.T) {
+	findings := []Finding{
+		{Vulnerability: FindingVulnerability{VulnID: "CVE-3", Severity: "LOW"}},
+		{Vulnerability: FindingVulnerability{VulnID: "CVE-2", Severity: "HIGH"}},
+		{Vulnerability: FindingVulnerability{VulnID: "CVE-1", Severity: "CRITICAL"}},
+		{Vulnerability: FindingVulnerability{VulnID: "CVE-0", Severity: "HIGH"}},
+	}
+
+	got := filterFindingsBySeverity(findings, []string{"CRITICAL", "HIGH"})
+	sortFindings(got)
+
+	var ids []string
+	for _, f := range got {
+		ids = append(ids, f.Vulnerability.VulnID)
+	}
+	if strings.Join(ids, ",") != "CVE-1,CVE-0,CVE-2" {
+		t.Errorf("got %v, want [CVE-1 CVE-0 CVE-2]", ids)
+	}
+}
+
+func TestWriteFindingsTable_AlignsColumns(t *testing.T) {
+	var buf bytes.Buffer
+	writeFindingsTable(&buf, []Finding{{
+		Component:     Component{Name: "lodash", Version: "4.17.20", PURL: "pkg:npm/lodash@4.17.20"},
+		Vulnerability: FindingVulnerability{VulnID: "CVE-2021-23337", Severity: "HIGH"},
+	}})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row, got:\n%s", buf.String())
+	}
+	if strings.Index(lines[0], "VULNERABILITY") != strings.Index(lines[1], "CVE-2021-23337") {
+		t.Errorf("columns not aligned:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[1], "pkg:npm/lodash") {
+		t.Errorf("row missing component: %q", lines[1])
+	}
+}
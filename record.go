@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// interaction is one recorded request and its response, stored as a numbered
+// JSON file in the --record directory.
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Body64 string      `json:"bodyBase64,omitempty"`
+}
+
+type recordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Body64 string      `json:"bodyBase64,omitempty"`
+}
+
+// sensitiveHeaders are replaced entirely in recordings; everything else is
+// passed through the secrets redactor.
+var sensitiveHeaders = []string{"X-Api-Key", "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+func redactHeader(h http.Header) http.Header {
+	out := http.Header{}
+	for name, values := range h {
+		for _, v := range values {
+			out.Add(name, secrets.redact(v))
+		}
+	}
+	for _, name := range sensitiveHeaders {
+		if out.Get(name) != "" {
+			out.Set(name, redacted)
+		}
+	}
+	return out
+}
+
+// encodeBody stores text bodies redacted and readable, and anything else as
+// base64.
+func encodeBody(b []byte) (text string, b64 string) {
+	if utf8.Valid(b) {
+		return secrets.redact(string(b)), ""
+	}
+	return "", base64.StdEncoding.EncodeToString(b)
+}
+
+func decodeBody(text string, b64 string) ([]byte, error) {
+	if b64 != "" {
+		return base64.StdEncoding.DecodeString(b64)
+	}
+	return []byte(text), nil
+}
+
+// recordingTransport writes every request passing through it, retries
+// included, and its response to dir. Each interaction is written as soon as
+// it completes, so a recording survives a failed run.
+type recordingTransport struct {
+	base http.RoundTripper
+	dir  string
+
+	mu    sync.Mutex
+	count int
+}
+
+func newRecordingTransport(base http.RoundTripper, dir string) (*recordingTransport, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create record directory: %w", err)
+	}
+	existing, err := cassetteFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("record directory %s already contains a recording", dir)
+	}
+	return &recordingTransport{base: base, dir: dir}, nil
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	rec := interaction{Request: recordedRequest{
+		Method: req.Method,
+		URL:    secrets.redact(req.URL.String()),
+		Header: redactHeader(req.Header),
+	}}
+	rec.Request.Body, rec.Request.Body64 = encodeBody(reqBody)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		// Connection errors have no response to replay; they are retried anyway.
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	rec.Response = recordedResponse{Status: resp.StatusCode, Header: redactHeader(resp.Header)}
+	rec.Response.Body, rec.Response.Body64 = encodeBody(respBody)
+
+	if err := t.write(rec); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *recordingTransport) write(rec interaction) error {
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.count++
+	path := filepath.Join(t.dir, fmt.Sprintf("%04d.json", t.count))
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
+// replayTransport answers requests with the responses in a recording, in
+// order. Each request must match the method and path (with query) of the next
+// recorded one, so a replay follows exactly the recorded flow.
+type replayTransport struct {
+	dir string
+
+	mu           sync.Mutex
+	interactions []interaction
+	next         int
+}
+
+func newReplayTransport(dir string) (*replayTransport, error) {
+	files, err := cassetteFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recording found in %s", dir)
+	}
+	t := &replayTransport{dir: dir}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recording: %w", err)
+		}
+		var rec interaction
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse recording %s: %w", f, err)
+		}
+		t.interactions = append(t.interactions, rec)
+	}
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.next >= len(t.interactions) {
+		return nil, fmt.Errorf("replay: unexpected request %s %s after the end of the recording in %s", req.Method, req.URL.RequestURI(), t.dir)
+	}
+	rec := t.interactions[t.next]
+	recordedURL, err := req.URL.Parse(rec.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("replay: invalid recorded URL %q: %w", rec.Request.URL, err)
+	}
+	if rec.Request.Method != req.Method || recordedURL.RequestURI() != req.URL.RequestURI() {
+		return nil, fmt.Errorf("replay: request %d is %s %s, but the recording has %s %s",
+			t.next+1, req.Method, req.URL.RequestURI(), rec.Request.Method, recordedURL.RequestURI())
+	}
+	t.next++
+
+	body, err := decodeBody(rec.Response.Body, rec.Response.Body64)
+	if err != nil {
+		return nil, fmt.Errorf("replay: invalid recorded body: %w", err)
+	}
+	header := rec.Response.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Response.Status, http.StatusText(rec.Response.Status)),
+		StatusCode:    rec.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func cassetteFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9][0-9].json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+// recordUpload runs a parent check and an SBOM upload against a server whose
+// first upload attempt fails, recording to dir.
+func recordUpload(t *testing.T, dir string) string {
+	t.Helper()
+	var uploads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/project/lookup":
+			_, _ = w.Write([]byte(`{"uuid":"parent-uuid","name":"my-parent"}`))
+		case "/api/v1/bom":
+			if uploads.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"recorded-token"}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &Config{APIKey: "record-secret-key", RetryMax: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond, Record: dir}
+	client := mustRetryClient(t, cfg)
+	if _, _, err := ensureParentExists(context.Background(), server.URL, "my-parent", "", client); err != nil {
+		t.Fatalf("ensureParentExists: %v", err)
+	}
+	token, err := uploadSbom(context.Background(), server.URL, "my-project", "my-parent", "1.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "", false, client)
+	if err != nil {
+		t.Fatalf("uploadSbom: %v", err)
+	}
+	return token
+}
+
+func TestRecord_WritesRedactedInteractions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cassette")
+	recordUpload(t, dir)
+
+	files, _ := cassetteFiles(dir)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 interactions (lookup, failed and retried upload), got %d", len(files))
+	}
+	var statuses []int
+	for _, f := range files {
+		b, _ := os.ReadFile(f)
+		if strings.Contains(string(b), "record-secret-key") {
+			t.Errorf("%s contains the API key", f)
+		}
+		var rec interaction
+		if err := json.Unmarshal(b, &rec); err != nil {
+			t.Fatalf("invalid recording %s: %v", f, err)
+		}
+		if rec.Request.Header.Get("X-Api-Key") != redacted {
+			t.Errorf("%s: X-Api-Key = %q, want %s", f, rec.Request.Header.Get("X-Api-Key"), redacted)
+		}
+		statuses = append(statuses, rec.Response.Status)
+	}
+	if statuses[1] != http.StatusServiceUnavailable || statuses[2] != http.StatusOK {
+		t.Errorf("statuses: got %v", statuses)
+	}
+
+	if _, err := newRecordingTransport(http.DefaultTransport, dir); err == nil {
+		t.Error("expected error when recording into a directory with a recording")
+	}
+}
+
+func TestReplay_RerunsRecordedFlowWithoutServer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cassette")
+	recordUpload(t, dir)
+
+	// No credential is needed to replay, and the server no longer exists.
+	cfg := &Config{AuthScheme: authSchemeAPIKey, RetryMax: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond, Replay: dir}
+	client := mustRetryClient(t, cfg)
+	url := "http://dtrack.invalid"
+	if _, _, err := ensureParentExists(context.Background(), url, "my-parent", "", client); err != nil {
+		t.Fatalf("ensureParentExists: %v", err)
+	}
+	token, err := uploadSbom(context.Background(), url, "my-project", "my-parent", "1.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "", false, client)
+	if err != nil {
+		t.Fatalf("uploadSbom: %v", err)
+	}
+	if token != "recorded-token" {
+		t.Errorf("token: got %q, want %q", token, "recorded-token")
+	}
+
+	if _, err := fetchProjectSummary(context.Background(), url, "my-project", "1.0.0", client); err == nil || !strings.Contains(err.Error(), "end of the recording") {
+		t.Errorf("expected end-of-recording error, got %v", err)
+	}
+}
+
+func TestReplay_WaitForMetricsWithoutWaiting(t *testing.T) {
+	server := httptest.NewServer(fakedtrack.New(fakedtrack.WithProcessingPolls(2)))
+	defer server.Close()
+	dir := filepath.Join(t.TempDir(), "cassette")
+	sbom := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX","components":[{"name":"lodash","version":"4.17.20"}]}`))
+	args := []string{"--name", "svc", "--version", "1.0.0", "--parent", "platform", "--sbom", sbom, "--wait-for-metrics", "--retry-max", "0"}
+
+	if _, err := runUpload(t, append(args, "--url", server.URL, "--api-key", "record-secret-key", "--poll-interval", "1ms", "--record", dir)...); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	// Only the recorded answers decide when the waits are over; the replay
+	// doesn't sleep between polls.
+	start := time.Now()
+	if _, err := runUpload(t, append(args, "--url", "http://dtrack.invalid", "--poll-interval", "10s", "--replay", dir)...); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("replay took %s, expected it not to wait between polls", elapsed)
+	}
+}
+
+func TestReplay_MismatchedRequestFails(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cassette")
+	recordUpload(t, dir)
+
+	client := mustRetryClient(t, &Config{AuthScheme: authSchemeAPIKey, Replay: dir})
+	_, _, err := ensureParentExists(context.Background(), "http://dtrack.invalid", "other-parent", "", client)
+	if err == nil || !strings.Contains(err.Error(), "but the recording has GET /api/v1/project/lookup?name=my-parent") {
+		t.Errorf("expected mismatch error, got %v", err)
+	}
+}
+
+func TestReplay_MissingRecording(t *testing.T) {
+	if _, err := newDefaultRetryClient(&Config{Replay: t.TempDir()}); err == nil {
+		t.Error("expected error for an empty replay directory, got nil")
+	}
+}
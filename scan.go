@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+func newScanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scan",
+		Short: "Scans an SBOM for known vulnerabilities against a local OSV database, without Dependency-Track",
+		Long: `Matches the package URLs of a CycloneDX JSON SBOM against a locally downloaded
+OSV database, such as the all.zip dumps from https://osv-vulnerabilities.storage.googleapis.com.
+--osv-db takes an OSV JSON file, a zip archive of them or a directory of either.
+
+The findings are reported like after an upload with --poll, and --kev-catalog
+fails the scan the same way.`,
+		RunE: runScan,
+	}
+	s := cmd.Flags()
+	s.String("sbom", "", "Path to SBOM file (optional; otherwise read from stdin)")
+	s.String("osv-db", "", "OSV database: a JSON file, zip archive or directory, or env SBOM_UPLOADER_OSV_DB")
+	s.Int("top-components", defaultTopComponents, "List this many of the most vulnerable components, 0 to disable, or env SBOM_UPLOADER_TOP_COMPONENTS")
+	s.String("kev-catalog", "", "CISA Known Exploited Vulnerabilities JSON file; fail if a finding is listed, or env SBOM_UPLOADER_KEV_CATALOG")
+	return cmd
+}
+
+func runScan(cmd *cobra.Command, _ []string) error {
+	cfg, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.OSVDB == "" {
+		return fmt.Errorf("missing required input: osv-db (via --osv-db or SBOM_UPLOADER_OSV_DB)")
+	}
+	if cfg.TopComponents < 0 {
+		return fmt.Errorf("invalid top-components %d: must not be negative", cfg.TopComponents)
+	}
+	cmd.SilenceUsage = true
+
+	sbomContent, err := readSbom(cfg.SBOM)
+	if err != nil {
+		return err
+	}
+	var gates findingGates
+	if cfg.KEVCatalog != "" {
+		if gates.KEV, err = loadKEVCatalog(cfg.KEVCatalog); err != nil {
+			return err
+		}
+	}
+	return scanOffline(cmd.OutOrStdout(), sbomContent, cfg.OSVDB, cfg.TopComponents, gates)
+}
+
+// scanOffline matches the SBOM against a local OSV database and reports the
+// findings like a run with --poll: the most vulnerable components, then any
+// failed gates.
+func scanOffline(w io.Writer, sbomContent []byte, osvDB string, topComponents int, gates findingGates) error {
+	bom, err := parseCycloneDX(bytes.TrimSpace(sbomContent))
+	if err != nil {
+		return fmt.Errorf("the offline scan needs a CycloneDX JSON SBOM: %w", err)
+	}
+	components := bom.allComponents()
+	wanted := map[osvPackage]bool{}
+	scannable := 0
+	for _, c := range components {
+		if pkg, _, ok := purlPackage(c.PURL); ok {
+			wanted[pkg] = true
+			scannable++
+		}
+	}
+	slog.Info("Loading OSV database...", "path", osvDB)
+	db, err := loadOSVDatabase(osvDB, wanted)
+	if err != nil {
+		return err
+	}
+	findings := matchOSV(components, db)
+	slog.Info("Offline scan complete.", "components", len(components), "scannable", scannable, "vulnerabilities", len(findings))
+
+	if risks := topVulnerableComponents(findings, topComponents); len(risks) > 0 {
+		writeTopComponents(w, risks, terminalWidth(w), useColor(w))
+	}
+	return gates.check(w, findings)
+}
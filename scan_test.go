@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+func TestScanCommand(t *testing.T) {
+	dir := t.TempDir()
+	osvDB := filepath.Join(dir, "log4j.json")
+	if err := os.WriteFile(osvDB, []byte(log4jOSV), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sbom := filepath.Join(dir, "sbom.json")
+	if err := os.WriteFile(sbom, []byte(`{"bomFormat":"CycloneDX","components":[
+		{"name":"log4j-core","version":"2.14.1","purl":"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+		{"name":"lodash","version":"4.17.21","purl":"pkg:npm/lodash@4.17.21"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cmd := newScanCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--sbom", sbom, "--osv-db", osvDB})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "log4j-core") || strings.Contains(got, "lodash") {
+		t.Errorf("expected only log4j-core among the vulnerable components:\n%s", got)
+	}
+
+	out.Reset()
+	cmd = newScanCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--sbom", sbom, "--osv-db", osvDB, "--kev-catalog", writeKEVCatalog(t, testKEVCatalog)})
+	var failedGates gateErrors
+	if err := cmd.Execute(); !errors.As(err, &failedGates) {
+		t.Fatalf("expected the KEV gate to fail, got %v", err)
+	}
+	if !strings.Contains(out.String(), "2021-12-24") {
+		t.Errorf("expected the KEV report:\n%s", out.String())
+	}
+}
+
+func TestScanCommand_Errors(t *testing.T) {
+	cmd := newScanCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--sbom", "missing.json"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "osv-db") {
+		t.Errorf("expected a missing osv-db error, got %v", err)
+	}
+
+	cmd = newScanCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--sbom", "missing.json", "--osv-db", "db", "--top-components", "-1"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("expected a negative top-components error, got %v", err)
+	}
+
+	if err := scanOffline(&bytes.Buffer{}, []byte(`<bom xmlns="http://cyclonedx.org/schema/bom/1.5"/>`), "db", 10, findingGates{}); err == nil || !strings.Contains(err.Error(), "CycloneDX JSON") {
+		t.Errorf("expected a format error, got %v", err)
+	}
+}
+
+func TestRunUploader_OSVFallback(t *testing.T) {
+	dir := t.TempDir()
+	osvDB := filepath.Join(dir, "log4j.json")
+	if err := os.WriteFile(osvDB, []byte(log4jOSV), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sbom := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX","components":[
+		{"name":"log4j-core","version":"2.14.1","purl":"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"}]}`))
+
+	for _, tc := range []struct {
+		name      string
+		failure   *fakedtrack.Failure
+		polls     int
+		args      []string
+		fallsBack bool
+	}{
+		{name: "upload 503", failure: &fakedtrack.Failure{Method: "POST", Path: "/api/v1/bom", Status: http.StatusServiceUnavailable}, fallsBack: true},
+		{name: "import timeout", polls: 1000, args: []string{"--poll", "--poll-timeout", "20ms"}, fallsBack: true},
+		{name: "unauthorized", args: []string{"--api-key", "wrong-key"}},
+		{name: "upload 400", failure: &fakedtrack.Failure{Method: "POST", Path: "/api/v1/bom", Status: http.StatusBadRequest}},
+		{name: "503 after the import", failure: &fakedtrack.Failure{Path: "/api/v1/metrics/project/", Status: http.StatusServiceUnavailable}, args: []string{"--wait-for-metrics"}},
+		{name: "EPSS gate", failure: &fakedtrack.Failure{Method: "POST", Path: "/api/v1/bom", Status: http.StatusServiceUnavailable}, args: []string{"--fail-on-epss", "0.1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := fakedtrack.New(fakedtrack.WithAPIKey("key"), fakedtrack.WithProcessingPolls(tc.polls))
+			server := httptest.NewServer(fake)
+			defer server.Close()
+			if tc.failure != nil {
+				fake.InjectFailure(*tc.failure)
+			}
+
+			args := []string{"--name", "svc", "--version", "1.0.0", "--parent", "platform", "--sbom", sbom, "--url", server.URL, "--api-key", "key",
+				"--retry-max", "0", "--poll-interval", "1ms", "--osv-fallback", osvDB}
+			out, err := runUpload(t, append(args, tc.args...)...)
+			scanned := strings.Contains(out, "log4j-core")
+			if tc.fallsBack && (err != nil || !scanned) {
+				t.Errorf("expected the offline scan to replace the upload, got %v:\n%s", err, out)
+			}
+			if !tc.fallsBack && (err == nil || scanned) {
+				t.Errorf("expected the error without an offline scan, got %v:\n%s", err, out)
+			}
+		})
+	}
+}
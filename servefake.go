@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+func newServeFakeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve-fake",
+		Short: "Runs an in-memory fake Dependency-Track server for offline testing",
+		Long: `Runs an in-memory fake of the Dependency-Track API subset this tool uses,
+for pipeline tests that shouldn't depend on a real instance. State is lost on exit.
+
+Failures can be injected with --fail "[METHOD ]PATH=STATUS[xCOUNT]", e.g.
+--fail "POST /api/v1/bom=503x2" fails the first two BOM uploads with 503.`,
+		RunE: runServeFake,
+	}
+	s := cmd.Flags()
+	s.String("listen", "127.0.0.1:8081", "Address to listen on")
+	s.String("api-key", "", "Require this API key (or bearer token); any credential is accepted if empty")
+	s.Int("processing-polls", 0, "Number of polls an upload token reports processing before it completes")
+	s.StringArray("fail", nil, "Inject a failure: [METHOD ]PATH=STATUS[xCOUNT] (repeatable)")
+	s.StringArray("team", nil, "Create a team with this name, for --team (repeatable)")
+	s.StringArray("notification-rule", nil, "Create a notification rule with this name, for --notification-rule (repeatable)")
+	s.StringArray("epss", nil, "Report an EPSS score on findings of a vulnerability: VULN_ID=SCORE (repeatable)")
+	return cmd
+}
+
+func runServeFake(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	listen, _ := cmd.Flags().GetString("listen")
+	apiKey, _ := cmd.Flags().GetString("api-key")
+	processingPolls, _ := cmd.Flags().GetInt("processing-polls")
+	failSpecs, _ := cmd.Flags().GetStringArray("fail")
+	teams, _ := cmd.Flags().GetStringArray("team")
+	rules, _ := cmd.Flags().GetStringArray("notification-rule")
+	epss, _ := cmd.Flags().GetStringArray("epss")
+
+	server := fakedtrack.New(fakedtrack.WithAPIKey(apiKey), fakedtrack.WithProcessingPolls(processingPolls))
+	for _, spec := range failSpecs {
+		f, err := fakedtrack.ParseFailure(spec)
+		if err != nil {
+			return err
+		}
+		server.InjectFailure(f)
+	}
+	for _, name := range teams {
+		server.AddTeam(name)
+	}
+	for _, name := range rules {
+		server.AddNotificationRule(name)
+	}
+	for _, spec := range epss {
+		vulnID, value, _ := strings.Cut(spec, "=")
+		score, err := strconv.ParseFloat(value, 64)
+		if vulnID == "" || err != nil || score < 0 || score > 1 {
+			return fmt.Errorf("invalid --epss %q: expected VULN_ID=SCORE with a score between 0 and 1", spec)
+		}
+		server.SetEPSS(vulnID, score, 0)
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+	httpServer := &http.Server{Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	// The URL goes to stdout so scripts can capture it, e.g. with --listen 127.0.0.1:0.
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "http://%s\n", listener.Addr())
+	slog.Info("Fake Dependency-Track server running, press Ctrl+C to stop.", "address", listener.Addr().String())
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- httpServer.Serve(listener) }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	// Stopping the fake is the normal way to end it, not a cancelled run.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+func TestFakeDTrack_UploadEndToEnd(t *testing.T) {
+	fake := fakedtrack.New(fakedtrack.WithAPIKey("e2e-api-key"), fakedtrack.WithProcessingPolls(2))
+	fake.InjectFailure(fakedtrack.Failure{Method: "POST", Path: "/api/v1/bom", Status: http.StatusServiceUnavailable, Count: 2})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	cfg := &Config{APIKey: "e2e-api-key", RetryMax: 3, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond}
+	client := mustRetryClient(t, cfg)
+	ctx := context.Background()
+	bom := []byte(`{"bomFormat":"CycloneDX","components":[{"bom-ref":"a","name":"lodash","version":"4.17.20"}],
+		"vulnerabilities":[{"id":"CVE-2021-23337","ratings":[{"severity":"high"}],"affects":[{"ref":"a"}]}]}`)
+
+	if _, _, err := ensureParentExists(ctx, server.URL, "platform", "team-a", client); err != nil {
+		t.Fatalf("ensureParentExists: %v", err)
+	}
+	token, err := uploadSbom(ctx, server.URL, "svc", "platform", "1.0.0", bom, "team-a", true, client)
+	if err != nil {
+		t.Fatalf("uploadSbom: %v", err)
+	}
+	if err := pollImport(ctx, server.URL, token, client, time.Millisecond, time.Second); err != nil {
+		t.Fatalf("pollImport: %v", err)
+	}
+	project, err := fetchProjectSummary(ctx, server.URL, "svc", "1.0.0", client)
+	if err != nil {
+		t.Fatalf("fetchProjectSummary: %v", err)
+	}
+	if err := refreshMetrics(ctx, server.URL, project.UUID, client, time.Millisecond, time.Second); err != nil {
+		t.Fatalf("refreshMetrics: %v", err)
+	}
+	if project.Metrics == nil || project.Metrics.Components != 1 || project.Metrics.Vulnerabilities != 1 || project.Metrics.InheritedRiskScore != 5 {
+		t.Errorf("unexpected metrics: %+v", project.Metrics)
+	}
+	if project.Parent == nil || project.Parent.Name != "platform" {
+		t.Errorf("expected parent platform, got %+v", project.Parent)
+	}
+	findings, err := fetchFindings(ctx, server.URL, project.UUID, false, client)
+	if err != nil {
+		t.Fatalf("fetchFindings: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Vulnerability.Severity != "HIGH" {
+		t.Errorf("unexpected findings: %+v", findings)
+	}
+
+	uploads := 0
+	for _, r := range fake.Requests() {
+		if r == "POST /api/v1/bom" {
+			uploads++
+		}
+	}
+	if uploads != 3 {
+		t.Errorf("expected the upload to be retried past 2 injected failures, got %d attempts", uploads)
+	}
+}
+
+func TestServeFake_ServesUntilCancelled(t *testing.T) {
+	cmd := newServeFakeCmd()
+	cmd.SetArgs([]string{"--listen", "127.0.0.1:0", "--fail", "GET /api/v1/project=500x1"})
+	pr, pw := io.Pipe()
+	cmd.SetOut(pw)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- cmd.ExecuteContext(ctx) }()
+
+	url, err := bufio.NewReader(pr).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read server URL: %v", err)
+	}
+	url = strings.TrimSpace(url)
+	for _, want := range []int{http.StatusInternalServerError, http.StatusOK} {
+		resp, err := http.Get(url + "/api/v1/project")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("got %d, want %d", resp.StatusCode, want)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve-fake did not stop after cancellation")
+	}
+}
+
+func TestServeFake_InvalidFailureSpec(t *testing.T) {
+	cmd := newServeFakeCmd()
+	cmd.SetArgs([]string{"--listen", "127.0.0.1:0", "--fail", "nonsense"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.ExecuteContext(context.Background()); err == nil {
+		t.Error("expected error for invalid --fail, got nil")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+type Team struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// accessManagementError explains a 403 from an endpoint that needs the
+// ACCESS_MANAGEMENT permission, which most upload keys don't have.
+func accessManagementError(what string, status int) error {
+	return fmt.Errorf("%s failed with status %d: the API key needs the ACCESS_MANAGEMENT permission to use --team", what, status)
+}
+
+// resolveTeams returns the teams identified by refs, each a team name or
+// UUID. Team names are looked up once; UUIDs are used as given.
+func resolveTeams(ctx context.Context, dependencyTrackUrl string, refs []string, client *retryablehttp.Client) ([]Team, error) {
+	var teams []Team
+	var all []Team
+	for _, ref := range refs {
+		if uuidPattern.MatchString(ref) {
+			teams = append(teams, Team{UUID: ref, Name: ref})
+			continue
+		}
+		if all == nil {
+			var err error
+			if all, err = listTeams(ctx, dependencyTrackUrl, client); err != nil {
+				return nil, err
+			}
+		}
+		i := -1
+		for j, t := range all {
+			if t.Name == ref {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("team %q not found", ref)
+		}
+		teams = append(teams, all[i])
+	}
+	return teams, nil
+}
+
+func listTeams(ctx context.Context, dependencyTrackUrl string, client *retryablehttp.Client) ([]Team, error) {
+	url := fmt.Sprintf("%s/api/v1/team", strings.TrimRight(dependencyTrackUrl, "/"))
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return nil, accessManagementError("listing teams", resp.StatusCode)
+	default:
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("listing teams failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	teams := []Team{}
+	if err := json.NewDecoder(resp.Body).Decode(&teams); err != nil {
+		return nil, fmt.Errorf("failed to parse team response: %w", err)
+	}
+	return teams, nil
+}
+
+// grantTeamAccess adds an ACL mapping from each team to the project. An
+// existing mapping is not an error.
+func grantTeamAccess(ctx context.Context, dependencyTrackUrl string, projectUUID string, teams []Team, client *retryablehttp.Client) error {
+	url := fmt.Sprintf("%s/api/v1/acl/mapping", strings.TrimRight(dependencyTrackUrl, "/"))
+	for _, team := range teams {
+		jsonBody, err := json.Marshal(map[string]string{"team": team.UUID, "project": projectUUID})
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		req, err := retryablehttp.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(jsonBody))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusConflict:
+		case http.StatusForbidden:
+			return accessManagementError("granting team "+team.Name+" access", resp.StatusCode)
+		default:
+			return fmt.Errorf("granting team %s access failed with status %d: %s", team.Name, resp.StatusCode, respBody)
+		}
+	}
+	return nil
+}
+
+// projectExists reports whether a project with the given name and version
+// exists.
+func projectExists(ctx context.Context, dependencyTrackUrl string, projectName string, projectVersion string, client *retryablehttp.Client) (bool, error) {
+	query := url.Values{"name": {projectName}, "version": {projectVersion}}
+	lookupURL := fmt.Sprintf("%s/api/v1/project/lookup?%s", strings.TrimRight(dependencyTrackUrl, "/"), query.Encode())
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", lookupURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		respBody, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("project lookup failed with status %d: %s", resp.StatusCode, respBody)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+func TestResolveTeams_NamesAndUUIDs(t *testing.T) {
+	var listCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listCalls.Add(1)
+		_ = json.NewEncoder(w).Encode([]Team{{UUID: "uuid-sec", Name: "Security"}, {UUID: "uuid-plat", Name: "Platform"}})
+	}))
+	defer server.Close()
+
+	const literal = "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	teams, err := resolveTeams(context.Background(), server.URL, []string{"Platform", literal, "Security"}, noRetryClient())
+	if err != nil {
+		t.Fatalf("resolveTeams: %v", err)
+	}
+	var got []string
+	for _, team := range teams {
+		got = append(got, team.UUID)
+	}
+	if want := "uuid-plat," + literal + ",uuid-sec"; strings.Join(got, ",") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+	if listCalls.Load() != 1 {
+		t.Errorf("expected teams to be listed once, got %d", listCalls.Load())
+	}
+}
+
+func TestResolveTeams_UUIDsNeedNoRequest(t *testing.T) {
+	teams, err := resolveTeams(context.Background(), "http://dtrack.invalid", []string{"3fa85f64-5717-4562-b3fc-2c963f66afa6"}, noRetryClient())
+	if err != nil || len(teams) != 1 {
+		t.Fatalf("got %v, %v", teams, err)
+	}
+}
+
+func TestResolveTeams_UnknownName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"uuid":"uuid-sec","name":"Security"}]`))
+	}))
+	defer server.Close()
+
+	_, err := resolveTeams(context.Background(), server.URL, []string{"security"}, noRetryClient())
+	if err == nil || !strings.Contains(err.Error(), `team "security" not found`) {
+		t.Errorf("expected a team not found error, got %v", err)
+	}
+}
+
+func TestResolveTeams_MissingPermission(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := resolveTeams(context.Background(), server.URL, []string{"Security"}, noRetryClient())
+	if err == nil || !strings.Contains(err.Error(), "ACCESS_MANAGEMENT") {
+		t.Errorf("expected an error naming ACCESS_MANAGEMENT, got %v", err)
+	}
+}
+
+func TestGrantTeamAccess_MissingPermission(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := grantTeamAccess(context.Background(), server.URL, "p-1", []Team{{UUID: "t-1", Name: "Security"}}, noRetryClient())
+	if err == nil || !strings.Contains(err.Error(), "ACCESS_MANAGEMENT") || !strings.Contains(err.Error(), "Security") {
+		t.Errorf("expected an error naming the team and ACCESS_MANAGEMENT, got %v", err)
+	}
+}
+
+func TestGrantTeamAccess_AgainstFake(t *testing.T) {
+	fake := fakedtrack.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	projectUUID := fake.AddProject(fakedtrack.Project{Name: "svc", Version: "1.0.0"})
+	fake.AddTeam("Security")
+	platform := fake.AddTeam("Platform")
+	ctx := context.Background()
+	client := noRetryClient()
+
+	teams, err := resolveTeams(ctx, server.URL, []string{"Platform"}, client)
+	if err != nil {
+		t.Fatalf("resolveTeams: %v", err)
+	}
+	// The second grant hits an existing mapping, which is fine.
+	for range 2 {
+		if err := grantTeamAccess(ctx, server.URL, projectUUID, teams, client); err != nil {
+			t.Fatalf("grantTeamAccess: %v", err)
+		}
+	}
+	if acl := fake.ACL(projectUUID); len(acl) != 1 || acl[0] != platform {
+		t.Errorf("expected only Platform to have access, got %v", acl)
+	}
+}
+
+func TestProjectExists(t *testing.T) {
+	fake := fakedtrack.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	fake.AddProject(fakedtrack.Project{Name: "svc", Version: "1.0.0"})
+
+	for version, want := range map[string]bool{"1.0.0": true, "2.0.0": false} {
+		got, err := projectExists(context.Background(), server.URL, "svc", version, noRetryClient())
+		if err != nil {
+			t.Fatalf("projectExists: %v", err)
+		}
+		if got != want {
+			t.Errorf("version %s: got %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestRunUploader_GrantsAccessOnlyToNewProjects(t *testing.T) {
+	const name = "svc+api & co"
+	sbom := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX","components":[]}`))
+	args := []string{"--name", name, "--version", "1.0 beta", "--parent", "platform & tools", "--sbom", sbom, "--api-key", "key", "--team", "Platform", "--retry-max", "0", "--poll-interval", "1ms"}
+
+	t.Run("new", func(t *testing.T) {
+		fake := fakedtrack.New()
+		server := httptest.NewServer(fake)
+		defer server.Close()
+		platform := fake.AddTeam("Platform")
+
+		if _, err := runUpload(t, append(args, "--url", server.URL)...); err != nil {
+			t.Fatalf("runUploader: %v", err)
+		}
+		parent, ok := fake.Project("platform & tools", "")
+		if !ok {
+			t.Fatal("parent was not created")
+		}
+		child, ok := fake.Project(name, "1.0 beta")
+		if !ok {
+			t.Fatal("project was not created")
+		}
+		for _, p := range []fakedtrack.Project{parent, child} {
+			if acl := fake.ACL(p.UUID); len(acl) != 1 || acl[0] != platform {
+				t.Errorf("%s: expected Platform to have access, got %v", p.Name, acl)
+			}
+		}
+		// The project has to be looked up before the upload creates it.
+		requests := fake.Requests()
+		upload := slices.Index(requests, "POST /api/v1/bom")
+		lookups := 0
+		for _, r := range requests[:max(upload, 0)] {
+			if r == "GET /api/v1/project/lookup" {
+				lookups++
+			}
+		}
+		if upload < 0 || lookups != 2 {
+			t.Errorf("expected the parent and project lookups before the upload, got %v", requests)
+		}
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		fake := fakedtrack.New()
+		server := httptest.NewServer(fake)
+		defer server.Close()
+		fake.AddTeam("Platform")
+		parent := fake.AddProject(fakedtrack.Project{Name: "platform & tools"})
+		child := fake.AddProject(fakedtrack.Project{Name: name, Version: "1.0 beta", Parent: &fakedtrack.ParentRef{UUID: parent, Name: "platform & tools"}})
+
+		if _, err := runUpload(t, append(args, "--url", server.URL)...); err != nil {
+			t.Fatalf("runUploader: %v", err)
+		}
+		for _, uuid := range []string{parent, child} {
+			if acl := fake.ACL(uuid); len(acl) != 0 {
+				t.Errorf("expected no access grants on an existing project, got %v", acl)
+			}
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// componentRisk summarises the unsuppressed findings of one component.
+type componentRisk struct {
+	Component Component
+	// Severity is the highest severity of the component's findings.
+	Severity string
+	// CVSS is the highest CVSS base score, preferring v3 over v2; 0 if unknown.
+	CVSS float64
+	// VulnIDs are ordered from most to least severe.
+	VulnIDs      []string
+	FixAvailable bool
+}
+
+// defaultTopComponents is how many of the most vulnerable components an upload
+// with --poll and the scan command list unless --top-components says otherwise.
+const defaultTopComponents = 10
+
+// topVulnerableComponents groups unsuppressed findings by component and
+// returns the n riskiest components: by highest severity, then CVSS, then
+// number of vulnerabilities. A negative n returns none.
+func topVulnerableComponents(findings []Finding, n int) []componentRisk {
+	n = max(n, 0)
+	findings = append([]Finding(nil), findings...)
+	sortFindings(findings)
+
+	var risks []*componentRisk
+	byComponent := map[string]*componentRisk{}
+	for _, f := range findings {
+		if f.Analysis.IsSuppressed {
+			continue
+		}
+		key := f.Component.UUID
+		if key == "" {
+			key = componentLabel(f.Component)
+		}
+		r, ok := byComponent[key]
+		if !ok {
+			r = &componentRisk{Component: f.Component, Severity: f.Vulnerability.Severity}
+			byComponent[key] = r
+			risks = append(risks, r)
+		}
+		r.VulnIDs = append(r.VulnIDs, f.Vulnerability.VulnID)
+		r.CVSS = max(r.CVSS, f.Vulnerability.cvss())
+		r.FixAvailable = r.FixAvailable || f.Vulnerability.PatchedVersions != ""
+	}
+
+	sort.SliceStable(risks, func(i, j int) bool {
+		a, b := risks[i], risks[j]
+		if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+			return ra > rb
+		}
+		if a.CVSS != b.CVSS {
+			return a.CVSS > b.CVSS
+		}
+		return len(a.VulnIDs) > len(b.VulnIDs)
+	})
+	out := make([]componentRisk, 0, min(n, len(risks)))
+	for _, r := range risks[:min(n, len(risks))] {
+		out = append(out, *r)
+	}
+	return out
+}
+
+// terminalWidth returns the width of the terminal w writes to, or 0 if it
+// isn't a terminal.
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// Columns that are shrunk to fit the terminal don't go below these widths.
+const (
+	minComponentWidth       = 20
+	minVulnerabilitiesWidth = 16
+)
+
+// writeTopComponents prints risks as a table. With width > 0 the component
+// and vulnerability columns are shortened to fit it; with color severities are
+// coloured.
+func writeTopComponents(w io.Writer, risks []componentRisk, width int, color bool) {
+	header := []string{"COMPONENT", "VERSION", "SEVERITY", "CVSS", "FIX", "VULNERABILITIES"}
+	rows := make([][]string, 0, len(risks))
+	for _, r := range risks {
+		cvss := "-"
+		if r.CVSS > 0 {
+			cvss = fmt.Sprintf("%.1f", r.CVSS)
+		}
+		fix := "no"
+		if r.FixAvailable {
+			fix = "yes"
+		}
+		rows = append(rows, []string{componentKey(r.Component), r.Component.Version, r.Severity, cvss, fix, strings.Join(r.VulnIDs, ", ")})
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	if width > 0 {
+		fixed := 2 * (len(header) - 1)
+		for i := 1; i < 5; i++ {
+			fixed += widths[i]
+		}
+		available := width - fixed
+		if widths[0]+widths[5] > available {
+			widths[5] = max(min(widths[5], available-widths[0]), minVulnerabilitiesWidth)
+			widths[0] = max(available-widths[5], minComponentWidth)
+		}
+		for i, r := range risks {
+			rows[i][0] = truncate(rows[i][0], widths[0])
+			rows[i][5] = fitVulnIDs(r.VulnIDs, widths[5])
+		}
+	}
+
+	var sb strings.Builder
+	for i, row := range append([][]string{header}, rows...) {
+		for j, cell := range row {
+			padded := cell
+			if j < len(row)-1 {
+				padded += strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)+2)
+			}
+			if color && i > 0 && j == 2 {
+				padded = severityColor(cell) + padded + colorReset
+			}
+			sb.WriteString(padded)
+		}
+		sb.WriteString("\n")
+	}
+	_, _ = io.WriteString(w, sb.String())
+}
+
+func severityColor(severity string) string {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL", "HIGH":
+		return colorRed
+	case "MEDIUM":
+		return colorYellow
+	default:
+		return colorGray
+	}
+}
+
+// truncate shortens s to n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// fitVulnIDs lists as many ids as fit in n runes, followed by the number of
+// ids left out.
+func fitVulnIDs(ids []string, n int) string {
+	list := ""
+	for i, id := range ids {
+		next := id
+		if i > 0 {
+			next = list + ", " + id
+		}
+		rest := len(ids) - i - 1
+		suffix := ""
+		if rest > 0 {
+			suffix = fmt.Sprintf(" +%d", rest)
+		}
+		if utf8.RuneCountInString(next+suffix) > n {
+			if list == "" {
+				return truncate(id, n)
+			}
+			return list + fmt.Sprintf(" +%d", len(ids)-i)
+		}
+		list = next
+	}
+	return list
+}
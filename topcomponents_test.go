@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+func riskFinding(uuid string, purl string, version string, vulnID string, severity string, cvss float64, patched string) Finding {
+	return Finding{
+		Component:     Component{UUID: uuid, Name: uuid, Version: version, PURL: purl},
+		Vulnerability: FindingVulnerability{VulnID: vulnID, Severity: severity, CVSSV3BaseScore: cvss, PatchedVersions: patched},
+	}
+}
+
+func TestTopVulnerableComponents(t *testing.T) {
+	suppressed := riskFinding("c-4", "pkg:npm/ignored@1.0.0", "1.0.0", "CVE-2024-0004", "CRITICAL", 10, "")
+	suppressed.Analysis.IsSuppressed = true
+	findings := []Finding{
+		riskFinding("c-1", "pkg:npm/lodash@4.17.20", "4.17.20", "CVE-2020-8203", "HIGH", 7.4, ""),
+		riskFinding("c-1", "pkg:npm/lodash@4.17.20", "4.17.20", "CVE-2021-23337", "HIGH", 7.2, "4.17.21"),
+		riskFinding("c-2", "pkg:npm/minimist@1.2.5", "1.2.5", "CVE-2021-44906", "CRITICAL", 9.8, ""),
+		riskFinding("c-3", "pkg:npm/qs@6.5.2", "6.5.2", "CVE-2022-24999", "HIGH", 7.5, ""),
+		riskFinding("c-5", "pkg:npm/debug@2.6.8", "2.6.8", "CVE-2017-16137", "MEDIUM", 5.3, ""),
+		suppressed,
+	}
+
+	risks := topVulnerableComponents(findings, 3)
+
+	var got []string
+	for _, r := range risks {
+		got = append(got, r.Component.UUID)
+	}
+	if strings.Join(got, ",") != "c-2,c-3,c-1" {
+		t.Fatalf("expected components by severity then CVSS, got %v", got)
+	}
+	lodash := risks[2]
+	if strings.Join(lodash.VulnIDs, ",") != "CVE-2020-8203,CVE-2021-23337" || lodash.CVSS != 7.4 || !lodash.FixAvailable {
+		t.Errorf("unexpected lodash summary: %+v", lodash)
+	}
+	if risks[0].FixAvailable {
+		t.Error("expected no fix for minimist")
+	}
+	if risks := topVulnerableComponents(findings, -1); len(risks) != 0 {
+		t.Errorf("expected no components for a negative count, got %d", len(risks))
+	}
+}
+
+func TestRunUploader_PollListsTopComponentsByDefault(t *testing.T) {
+	server := httptest.NewServer(fakedtrack.New())
+	defer server.Close()
+	sbom := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX","components":[{"bom-ref":"a","name":"lodash","version":"4.17.20","purl":"pkg:npm/lodash@4.17.20"}],
+		"vulnerabilities":[{"id":"CVE-2021-23337","ratings":[{"severity":"high"}],"affects":[{"ref":"a"}]}]}`))
+	args := []string{"--name", "svc", "--version", "1.0.0", "--parent", "platform", "--sbom", sbom, "--url", server.URL, "--api-key", "key", "--poll", "--poll-interval", "1ms"}
+
+	out, err := runUpload(t, args...)
+	if err != nil {
+		t.Fatalf("runUploader: %v", err)
+	}
+	if !strings.Contains(out, "COMPONENT") || !strings.Contains(out, "pkg:npm/lodash") || !strings.Contains(out, "CVE-2021-23337") {
+		t.Errorf("expected the most vulnerable components after --poll:\n%s", out)
+	}
+
+	out, err = runUpload(t, append(args, "--top-components", "0")...)
+	if err != nil {
+		t.Fatalf("runUploader: %v", err)
+	}
+	if out != "" {
+		t.Errorf("expected no table with --top-components 0, got:\n%s", out)
+	}
+}
+
+func TestWriteTopComponents_Plain(t *testing.T) {
+	risks := []componentRisk{
+		{Component: Component{Name: "lodash", Version: "4.17.20", PURL: "pkg:npm/lodash@4.17.20"}, Severity: "HIGH", CVSS: 7.4, VulnIDs: []string{"CVE-2020-8203", "CVE-2021-23337"}, FixAvailable: true},
+		{Component: Component{Name: "internal-lib", Version: "1.0"}, Severity: "UNASSIGNED", VulnIDs: []string{"INT-1"}},
+	}
+	var out bytes.Buffer
+	writeTopComponents(&out, risks, 0, false)
+
+	want := "COMPONENT       VERSION  SEVERITY    CVSS  FIX  VULNERABILITIES\n" +
+		"pkg:npm/lodash  4.17.20  HIGH        7.4   yes  CVE-2020-8203, CVE-2021-23337\n" +
+		"internal-lib    1.0      UNASSIGNED  -     no   INT-1\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWriteTopComponents_FitsTerminalWidth(t *testing.T) {
+	ids := []string{"CVE-2021-0001", "CVE-2021-0002", "CVE-2021-0003", "CVE-2021-0004", "CVE-2021-0005"}
+	risks := []componentRisk{{
+		Component: Component{Version: "1.0.0", PURL: "pkg:maven/org.example.very.long.group.name/some-really-long-artifact-name@1.0.0"},
+		Severity:  "CRITICAL", CVSS: 9.8, VulnIDs: ids,
+	}}
+	var out bytes.Buffer
+	writeTopComponents(&out, risks, 80, true)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	row := strings.NewReplacer(colorRed, "", colorReset, "").Replace(lines[1])
+	if n := utf8.RuneCountInString(row); n > 80 {
+		t.Errorf("expected the row to fit in 80 columns, got %d: %q", n, row)
+	}
+	if !strings.Contains(row, "…") || !strings.Contains(row, "CVE-2021-0001") || !strings.Contains(row, " +") {
+		t.Errorf("expected a truncated component and a shortened vulnerability list, got %q", row)
+	}
+	if !strings.Contains(lines[1], colorRed+"CRITICAL") {
+		t.Errorf("expected the severity to be coloured, got %q", lines[1])
+	}
+}
+
+func TestFitVulnIDs(t *testing.T) {
+	ids := []string{"CVE-1", "CVE-2", "CVE-3"}
+	for n, want := range map[int]string{
+		50: "CVE-1, CVE-2, CVE-3",
+		19: "CVE-1, CVE-2, CVE-3",
+		18: "CVE-1, CVE-2 +1",
+		10: "CVE-1 +2",
+		4:  "CVE…",
+	} {
+		if got := fitVulnIDs(ids, n); got != want {
+			t.Errorf("fitVulnIDs(%d): got %q, want %q", n, got, want)
+		}
+	}
+}
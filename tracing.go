@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracer creates all spans of the tool. Until setupTracing replaces it, it
+// is a no-op.
+var tracer trace.Tracer = noop.NewTracerProvider().Tracer("")
+
+// shutdownTracing flushes pending spans and closes the exporters. It is
+// replaced by setupTracing and called once on exit.
+var shutdownTracing = func(context.Context) error { return nil }
+
+// setupTracing installs a tracer provider exporting to a JSON file
+// (--trace-file) and/or OTLP over HTTP (--trace-otlp-endpoint, or the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT variables). Without any of them tracing stays
+// disabled. The returned context carries the parent span from the TRACEPARENT
+// environment variable, so the spans join the CI pipeline's trace.
+func setupTracing(ctx context.Context, cfg *Config) (context.Context, error) {
+	var opts []sdktrace.TracerProviderOption
+	var file *os.File
+	if cfg.TraceFile != "" {
+		var err error
+		if file, err = os.Create(cfg.TraceFile); err != nil {
+			return ctx, fmt.Errorf("failed to create trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			_ = file.Close()
+			return ctx, fmt.Errorf("failed to create trace file exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	if cfg.TraceOTLPEndpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		var otlpOpts []otlptracehttp.Option
+		if cfg.TraceOTLPEndpoint != "" {
+			otlpOpts = append(otlpOpts, otlptracehttp.WithEndpointURL(cfg.TraceOTLPEndpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, otlpOpts...)
+		if err != nil {
+			return ctx, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	if len(opts) == 0 {
+		return ctx, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName("upload-sbom-go")))
+	if err != nil {
+		return ctx, err
+	}
+	provider := sdktrace.NewTracerProvider(append(opts, sdktrace.WithResource(res))...)
+	otel.SetTracerProvider(provider)
+	tracer = provider.Tracer("upload-sbom-go")
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	shutdownTracing = func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}
+
+	carrier := propagation.MapCarrier{}
+	if tp := os.Getenv("TRACEPARENT"); tp != "" {
+		carrier.Set("traceparent", tp)
+		carrier.Set("tracestate", os.Getenv("TRACESTATE"))
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier), nil
+}
+
+// traced runs fn in a span named name, recording its error.
+func traced(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, name)
+	defer span.End()
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, secrets.redact(err.Error()))
+	}
+}
+
+// tracingTransport creates a client span for every HTTP attempt, retries
+// included, and propagates the trace context to the server.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(secrets.redact(req.URL.String())),
+			semconv.ServerAddress(req.URL.Hostname()),
+		))
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+// projectAttributes identifies the project a workflow span belongs to.
+func projectAttributes(cfg *Config) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("dependencytrack.project.name", cfg.Name),
+		attribute.String("dependencytrack.project.version", cfg.Version),
+		attribute.String("dependencytrack.project.parent", cfg.Parent),
+	}
+}
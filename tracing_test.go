@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"upload-sbom-go/fakedtrack"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+type exportedSpan struct {
+	Name        string
+	SpanContext struct{ TraceID, SpanID string }
+	Parent      struct{ TraceID, SpanID string }
+	Attributes  []struct {
+		Key   string
+		Value struct{ Value any }
+	}
+}
+
+// setupTestTracing enables tracing to a temp file and returns a function that
+// flushes it and reads back the spans.
+func setupTestTracing(t *testing.T) (context.Context, func() []exportedSpan) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trace.json")
+	prevTracer, prevProvider, prevPropagator := tracer, otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		_ = shutdownTracing(context.Background())
+		shutdownTracing = func(context.Context) error { return nil }
+		tracer = prevTracer
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	ctx, err := setupTracing(context.Background(), &Config{TraceFile: path})
+	if err != nil {
+		t.Fatalf("setupTracing: %v", err)
+	}
+	return ctx, func() []exportedSpan {
+		t.Helper()
+		if err := shutdownTracing(context.Background()); err != nil {
+			t.Fatalf("shutdownTracing: %v", err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open trace file: %v", err)
+		}
+		defer f.Close()
+		var spans []exportedSpan
+		for dec := json.NewDecoder(f); ; {
+			var span exportedSpan
+			if err := dec.Decode(&span); errors.Is(err, io.EOF) {
+				return spans
+			} else if err != nil {
+				t.Fatalf("invalid trace file: %v", err)
+			}
+			spans = append(spans, span)
+		}
+	}
+}
+
+func TestTracing_SpansJoinTraceParentAndCoverRetries(t *testing.T) {
+	t.Setenv("TRACEPARENT", testTraceParent)
+	ctx, readSpans := setupTestTracing(t)
+
+	fake := fakedtrack.New()
+	fake.InjectFailure(fakedtrack.Failure{Method: "POST", Path: "/api/v1/bom", Status: http.StatusServiceUnavailable, Count: 1})
+	var mu sync.Mutex
+	var traceParents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceParents = append(traceParents, r.Header.Get("traceparent"))
+		mu.Unlock()
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	cfg := &Config{APIKey: "test-key", RetryMax: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond}
+	client := mustRetryClient(t, cfg)
+	err := traced(ctx, "upload SBOM", func(ctx context.Context) error {
+		_, err := uploadSbom(ctx, server.URL, "svc", "", "1.0.0", []byte(`{"bomFormat":"CycloneDX"}`), "", false, client)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("uploadSbom: %v", err)
+	}
+
+	spans := readSpans()
+	var upload exportedSpan
+	var attempts []exportedSpan
+	for _, s := range spans {
+		switch s.Name {
+		case "upload SBOM":
+			upload = s
+		case "HTTP POST":
+			attempts = append(attempts, s)
+		}
+	}
+	if upload.Parent.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || upload.Parent.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("expected the upload span to continue TRACEPARENT, got parent %+v", upload.Parent)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("expected a span per attempt (2), got %d in %+v", len(attempts), spans)
+	}
+	for _, a := range attempts {
+		if a.Parent.SpanID != upload.SpanContext.SpanID {
+			t.Errorf("expected HTTP span to be a child of the upload span, got parent %+v", a.Parent)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, tp := range traceParents {
+		if !strings.HasPrefix(tp, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+			t.Errorf("request %d: expected traceparent in the same trace, got %q", i+1, tp)
+		}
+	}
+}
+
+func TestTracing_PollIterationsGetSpans(t *testing.T) {
+	ctx, readSpans := setupTestTracing(t)
+
+	calls := 0
+	err := pollUntil(ctx, "import", time.Millisecond, time.Second, func(context.Context) (bool, error) {
+		calls++
+		return calls == 3, nil
+	})
+	if err != nil {
+		t.Fatalf("pollUntil: %v", err)
+	}
+
+	var attempts []any
+	for _, s := range readSpans() {
+		if s.Name != "poll import" {
+			continue
+		}
+		for _, a := range s.Attributes {
+			if a.Key == "poll.attempt" {
+				attempts = append(attempts, a.Value.Value)
+			}
+		}
+	}
+	if len(attempts) != 3 {
+		t.Errorf("expected 3 poll spans with an attempt number, got %v", attempts)
+	}
+}
+
+func TestTracing_DisabledWithoutExporter(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("TRACEPARENT", testTraceParent)
+	ctx, err := setupTracing(context.Background(), &Config{})
+	if err != nil {
+		t.Fatalf("setupTracing: %v", err)
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	if carrier.Get("traceparent") != "" {
+		t.Errorf("expected no trace context when tracing is disabled, got %q", carrier.Get("traceparent"))
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// configureTransport applies the TLS and proxy settings from cfg to t.
+// Without any of them set, t keeps Go's defaults: the system trust store and
+// the HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables.
+func configureTransport(t *http.Transport, cfg *Config) error {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no PEM certificates found in CA certificate file %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.InsecureSkipVerify {
+		slog.Warn("TLS certificate verification is DISABLED (--insecure-skip-verify).")
+		slog.Warn("The API key can be intercepted by anyone able to impersonate the server. Use --ca-cert instead.")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	t.TLSClientConfig = tlsConfig
+
+	if cfg.Proxy != "" {
+		if _, err := url.Parse(cfg.Proxy); err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  cfg.Proxy,
+			HTTPSProxy: cfg.Proxy,
+			NoProxy:    cfg.NoProxy,
+		}).ProxyFunc()
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+	return nil
+}
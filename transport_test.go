@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes a single PEM block to a temp file and returns its path.
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write PEM: %v", err)
+	}
+	return path
+}
+
+// newClientCertificate generates a self-signed client certificate and returns
+// it parsed, along with the paths of its PEM certificate and key files.
+func newClientCertificate(t *testing.T) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sbom-uploader"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return cert, writePEM(t, "CERTIFICATE", der), writePEM(t, "EC PRIVATE KEY", keyDER)
+}
+
+func transportClient(t *testing.T, cfg *Config) *http.Client {
+	t.Helper()
+	transport := &http.Transport{}
+	if err := configureTransport(transport, cfg); err != nil {
+		t.Fatalf("configureTransport: %v", err)
+	}
+	return &http.Client{Transport: transport}
+}
+
+func TestConfigureTransport_UntrustedServerFails(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := transportClient(t, &Config{}).Get(server.URL); err == nil {
+		t.Error("expected certificate error for untrusted server, got nil")
+	}
+}
+
+func TestConfigureTransport_CACertTrustsServer(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caPath := writePEM(t, "CERTIFICATE", server.Certificate().Raw)
+	resp, err := transportClient(t, &Config{CACert: caPath}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestConfigureTransport_InsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	resp, err := transportClient(t, &Config{InsecureSkipVerify: true}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestConfigureTransport_ClientCertificate(t *testing.T) {
+	clientCert, certPath, keyPath := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caPath := writePEM(t, "CERTIFICATE", server.Certificate().Raw)
+
+	if _, err := transportClient(t, &Config{CACert: caPath}).Get(server.URL); err == nil {
+		t.Error("expected handshake failure without a client certificate, got nil")
+	}
+
+	resp, err := transportClient(t, &Config{CACert: caPath, ClientCert: certPath, ClientKey: keyPath}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error with client certificate: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestConfigureTransport_InvalidCACertReturnsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	_ = os.WriteFile(path, []byte("not a certificate"), 0o600)
+
+	if err := configureTransport(&http.Transport{}, &Config{CACert: path}); err == nil {
+		t.Error("expected error for CA file without certificates, got nil")
+	}
+	if err := configureTransport(&http.Transport{}, &Config{CACert: "/nonexistent/ca.pem"}); err == nil {
+		t.Error("expected error for missing CA file, got nil")
+	}
+}
+
+func TestConfigureTransport_ProxyHonoursNoProxy(t *testing.T) {
+	transport := &http.Transport{}
+	cfg := &Config{Proxy: "http://proxy.internal:3128", NoProxy: "dtrack.internal"}
+	if err := configureTransport(transport, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxied, _ := url.Parse("https://dtrack.example.com/api/v1/bom")
+	got, err := transport.Proxy(&http.Request{URL: proxied})
+	if err != nil || got == nil || got.Host != "proxy.internal:3128" {
+		t.Errorf("proxy for %s: got (%v, %v), want proxy.internal:3128", proxied, got, err)
+	}
+
+	bypassed, _ := url.Parse("https://dtrack.internal/api/v1/bom")
+	got, err = transport.Proxy(&http.Request{URL: bypassed})
+	if err != nil || got != nil {
+		t.Errorf("proxy for %s: got (%v, %v), want direct", bypassed, got, err)
+	}
+}
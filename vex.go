@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/spf13/cobra"
+)
+
+func newVexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "vex",
+		Short: "Uploads a CycloneDX VEX document to an existing project version",
+		RunE:  runVex,
+	}
+	s := cmd.Flags()
+	setConnectionFlags(s)
+	s.String("name", "", "Project name or env SBOM_UPLOADER_NAME")
+	s.String("version", "", "Project version or env SBOM_UPLOADER_VERSION")
+	s.String("vex", "", "Path to CycloneDX VEX file or env SBOM_UPLOADER_VEX")
+	s.Bool("poll", false, "Poll until the VEX is processed or env SBOM_UPLOADER_POLL")
+	setPollFlags(s)
+	return cmd
+}
+
+func runVex(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	cfg, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.validateConnection(); err != nil {
+		return err
+	}
+	if cfg.Name == "" {
+		return fmt.Errorf("missing required input: name (via --name or SBOM_UPLOADER_NAME)")
+	}
+	if cfg.Version == "" {
+		return fmt.Errorf("missing required input: version (via --version or SBOM_UPLOADER_VERSION)")
+	}
+	if cfg.VEX == "" {
+		return fmt.Errorf("missing required input: vex (via --vex or SBOM_UPLOADER_VEX)")
+	}
+
+	client, err := newDefaultRetryClient(cfg)
+	if err != nil {
+		return err
+	}
+	token, err := uploadVex(ctx, cfg.URL, cfg.Name, cfg.Version, cfg.VEX, client)
+	if err != nil {
+		return err
+	}
+	slog.Info("VEX upload successful.")
+	if cfg.Poll {
+		slog.Info("Polling until VEX is processed...")
+		if err := pollImport(ctx, cfg.URL, token, client, cfg.pollWait(), cfg.PollTimeout); err != nil {
+			return err
+		}
+		slog.Info("VEX processed successfully.")
+	}
+	return nil
+}
+
+// uploadVex uploads a CycloneDX VEX document for an existing project version.
+// Dependency-Track only applies VEX statements to components it already knows
+// about, so the project's BOM must have finished importing first.
+func uploadVex(ctx context.Context, dependencyTrackUrl string, projectName string, projectVersion string, vexFilePath string, client *retryablehttp.Client) (string, error) {
+	slog.Info("Reading VEX from file...", "path", vexFilePath)
+	vexContent, err := os.ReadFile(vexFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read VEX file: %w", err)
+	}
+	slog.Info("VEX file read.", "bytes", len(vexContent))
+
+	var requestBody bytes.Buffer
+	writer := multipart.NewWriter(&requestBody)
+
+	vexPart, err := writer.CreateFormFile("vex", "vex.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to create VEX form part: %w", err)
+	}
+	if _, err := vexPart.Write(vexContent); err != nil {
+		return "", fmt.Errorf("failed to write VEX content: %w", err)
+	}
+
+	_ = writer.WriteField("projectName", projectName)
+	_ = writer.WriteField("projectVersion", projectVersion)
+
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to finalize multipart body: %w", err)
+	}
+
+	slog.Info("Uploading VEX...", "project", projectName, "version", projectVersion)
+	url := fmt.Sprintf("%s/api/v1/vex", strings.TrimRight(dependencyTrackUrl, "/"))
+	req, err := retryablehttp.NewRequestWithContext(ctx, "POST", url, &requestBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("VEX upload failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	var uploadResp struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&uploadResp); err != nil {
+		return "", fmt.Errorf("failed to parse VEX upload response: %w", err)
+	}
+	slog.Info("VEX queued for processing.", "token", uploadResp.Token)
+	return uploadResp.Token, nil
+}
+
+func newExportVexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export-vex",
+		Short: "Downloads a project's analysis decisions from Dependency-Track as a CycloneDX VEX file",
+		RunE:  runExportVex,
+	}
+	s := cmd.Flags()
+	setConnectionFlags(s)
+	s.String("name", "", "Project name or env SBOM_UPLOADER_NAME")
+	s.String("version", "", "Project version or env SBOM_UPLOADER_VERSION")
+	s.String("uuid", "", "Project UUID (instead of --name and --version)")
+	s.StringP("output", "o", "vex.json", "Path to write the VEX file to")
+	s.Bool("normalize", false, "Strip volatile fields and sort vulnerabilities for stable diffs")
+	return cmd
+}
+
+func runExportVex(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	cfg, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.validateConnection(); err != nil {
+		return err
+	}
+	uuid, _ := cmd.Flags().GetString("uuid")
+	output, _ := cmd.Flags().GetString("output")
+	normalize, _ := cmd.Flags().GetBool("normalize")
+
+	client, err := newDefaultRetryClient(cfg)
+	if err != nil {
+		return err
+	}
+	uuid, err = resolveProjectUUID(ctx, cfg, uuid, client)
+	if err != nil {
+		return err
+	}
+
+	vex, err := fetchVex(ctx, cfg.URL, uuid, client)
+	if err != nil {
+		return err
+	}
+	if normalize {
+		if vex, err = normalizeVex(vex); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(output, vex, 0o644); err != nil {
+		return fmt.Errorf("failed to write VEX file: %w", err)
+	}
+	slog.Info("VEX written.", "uuid", uuid, "path", output, "bytes", len(vex))
+	return nil
+}
+
+func fetchVex(ctx context.Context, dependencyTrackUrl string, projectUUID string, client *retryablehttp.Client) ([]byte, error) {
+	slog.Info("Exporting VEX...", "uuid", projectUUID)
+	url := fmt.Sprintf("%s/api/v1/vex/cyclonedx/project/%s", strings.TrimRight(dependencyTrackUrl, "/"), projectUUID)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("VEX export failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VEX response: %w", err)
+	}
+	return body, nil
+}
+
+// normalizeVex rewrites a CycloneDX VEX document so that exporting the same
+// analysis twice produces identical bytes: the serial number and timestamp that
+// change on every export are removed, vulnerabilities and their affected refs
+// are sorted, and the output is indented with sorted object keys.
+func normalizeVex(vex []byte) ([]byte, error) {
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(vex))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse VEX document: %w", err)
+	}
+	delete(doc, "serialNumber")
+	if metadata, ok := doc["metadata"].(map[string]any); ok {
+		delete(metadata, "timestamp")
+	}
+
+	if vulns, ok := doc["vulnerabilities"].([]any); ok {
+		for _, v := range vulns {
+			if vuln, ok := v.(map[string]any); ok {
+				if affects, ok := vuln["affects"].([]any); ok {
+					sort.SliceStable(affects, func(i, j int) bool {
+						return stringField(affects[i], "ref") < stringField(affects[j], "ref")
+					})
+				}
+			}
+		}
+		sort.SliceStable(vulns, func(i, j int) bool {
+			if a, b := stringField(vulns[i], "id"), stringField(vulns[j], "id"); a != b {
+				return a < b
+			}
+			return stringField(vulns[i], "bom-ref") < stringField(vulns[j], "bom-ref")
+		})
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal VEX document: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// stringField returns the string value of key in a decoded JSON object, or ""
+// when v isn't an object or the value isn't a string.
+func stringField(v any, key string) string {
+	obj, _ := v.(map[string]any)
+	s, _ := obj[key].(string)
+	return s
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// --- uploadVex ---
+
+func TestUploadVex_SendsFormFields(t *testing.T) {
+	var gotProjectName, gotVersion, gotVex string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/vex" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("X-Api-Key") != "test-key" {
+			t.Errorf("unexpected API key: %s", r.Header.Get("X-Api-Key"))
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse multipart form: %v", err)
+		}
+		gotProjectName = r.FormValue("projectName")
+		gotVersion = r.FormValue("projectVersion")
+		if f, _, err := r.FormFile("vex"); err == nil {
+			b, _ := io.ReadAll(f)
+			gotVex = string(b)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "vex-token"})
+	}))
+	defer server.Close()
+
+	vexPath := writeTempSbom(t, []byte(`{"bomFormat":"CycloneDX","vulnerabilities":[]}`))
+
+	token, err := uploadVex(context.Background(), server.URL, "my-project", "1.0.0", vexPath, noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "vex-token" {
+		t.Errorf("token: got %q, want %q", token, "vex-token")
+	}
+	if gotProjectName != "my-project" {
+		t.Errorf("projectName: got %q, want %q", gotProjectName, "my-project")
+	}
+	if gotVersion != "1.0.0" {
+		t.Errorf("projectVersion: got %q, want %q", gotVersion, "1.0.0")
+	}
+	if gotVex != `{"bomFormat":"CycloneDX","vulnerabilities":[]}` {
+		t.Errorf("vex: got %q", gotVex)
+	}
+}
+
+func TestUploadVex_NonOKStatusReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `The project could not be found.`)
+	}))
+	defer server.Close()
+
+	vexPath := writeTempSbom(t, []byte(`{}`))
+
+	_, err := uploadVex(context.Background(), server.URL, "my-project", "1.0.0", vexPath, noRetryClient())
+	if err == nil {
+		t.Error("expected error for HTTP 404, got nil")
+	}
+}
+
+func TestUploadVex_MissingFileReturnsError(t *testing.T) {
+	_, err := uploadVex(context.Background(), "http://localhost", "proj", "1.0", "/nonexistent/vex.json", noRetryClient())
+	if err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+}
+
+// --- fetchVex ---
+
+func TestFetchVex_ReturnsBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/vex/cyclonedx/project/abc-123" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		_, _ = io.WriteString(w, `{"bomFormat":"CycloneDX"}`)
+	}))
+	defer server.Close()
+
+	got, err := fetchVex(context.Background(), server.URL, "abc-123", noRetryClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != `{"bomFormat":"CycloneDX"}` {
+		t.Errorf("body: got %q", got)
+	}
+}
+
+func TestFetchVex_NonOKStatusReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if _, err := fetchVex(context.Background(), server.URL, "abc-123", noRetryClient()); err == nil {
+		t.Error("expected error for HTTP 403, got nil")
+	}
+}
+
+// --- normalizeVex ---
+
+func TestNormalizeVex_IsStableAcrossExports(t *testing.T) {
+	first := []byte(`{
+		"serialNumber": "urn:uuid:1111",
+		"metadata": {"timestamp": "2024-01-01T00:00:00Z", "component": {"name": "app"}},
+		"vulnerabilities": [
+			{"id": "CVE-2024-0002", "affects": [{"ref": "b"}, {"ref": "a"}]},
+			{"id": "CVE-2024-0001", "analysis": {"state": "not_affected"}}
+		]
+	}`)
+	second := []byte(`{
+		"vulnerabilities": [
+			{"analysis": {"state": "not_affected"}, "id": "CVE-2024-0001"},
+			{"affects": [{"ref": "a"}, {"ref": "b"}], "id": "CVE-2024-0002"}
+		],
+		"metadata": {"component": {"name": "app"}, "timestamp": "2024-06-01T00:00:00Z"},
+		"serialNumber": "urn:uuid:2222"
+	}`)
+
+	a, err := normalizeVex(first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := normalizeVex(second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("normalized output differs:\n%s\n---\n%s", a, b)
+	}
+	if strings.Contains(string(a), "serialNumber") || strings.Contains(string(a), "timestamp") {
+		t.Errorf("volatile fields not removed:\n%s", a)
+	}
+	if strings.Index(string(a), "CVE-2024-0001") > strings.Index(string(a), "CVE-2024-0002") {
+		t.Errorf("vulnerabilities not sorted by id:\n%s", a)
+	}
+}
+
+func TestNormalizeVex_InvalidJSONReturnsError(t *testing.T) {
+	if _, err := normalizeVex([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON, got nil")
+	}
+}